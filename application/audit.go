@@ -0,0 +1,182 @@
+package application
+
+import (
+	"book-apis/domain"
+	"book-apis/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// maxUnpinnedAttempts bounds how often a write made without an expected
+// version is retried when another write lands between reading the book,
+// for its audit entry or to edit it, and changing it.
+const maxUnpinnedAttempts = 3
+
+func (s *BookService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.tx == nil {
+		return fn(ctx)
+	}
+	return s.tx.WithinTx(ctx, fn)
+}
+
+// retryUnpinned runs fn in a transaction, again if it went stale while
+// version is 0. Audited writes are pinned to the version they diffed
+// against, so a caller who asked for no version check must not see the
+// conflict that causes.
+func (s *BookService) retryUnpinned(ctx context.Context, version int, fn func(ctx context.Context) error) error {
+	if s.audit == nil {
+		return s.withinTx(ctx, fn)
+	}
+	return s.retryStale(ctx, version, fn)
+}
+
+// retryStale runs fn in a transaction, again if it went stale while
+// version is 0.
+func (s *BookService) retryStale(ctx context.Context, version int, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.withinTx(ctx, fn)
+		if version != 0 || attempt == maxUnpinnedAttempts || !errors.Is(err, domain.ErrStale) {
+			return err
+		}
+	}
+}
+
+// snapshot reads book ID as it stands before an audited write and pins
+// *version to it. Without an audit log it reads nothing.
+func (s *BookService) snapshot(ctx context.Context, ID int, version *int) (*domain.Book, error) {
+	if s.audit == nil {
+		return nil, nil
+	}
+	book, err := s.service.GetBook(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if *version != 0 && *version != book.Version {
+		return nil, &domain.StaleError{Resource: "book", ID: ID}
+	}
+	*version = book.Version
+	return &book, nil
+}
+
+func (s *BookService) record(ctx context.Context, action string, ID int, changes map[string]domain.Change) error {
+	return recordAudit(ctx, s.audit, action, ID, changes)
+}
+
+// recordAudit adds an entry for book ID to audit, if there is one, made by
+// the principal in ctx or by "system" when there is none.
+func recordAudit(ctx context.Context, audit domain.AuditRepository, action string, ID int, changes map[string]domain.Change) error {
+	if audit == nil {
+		return nil
+	}
+	actor := "system"
+	if p, ok := domain.PrincipalFromContext(ctx); ok {
+		actor = p.Subject
+	}
+	return audit.RecordAudit(ctx, &domain.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		BookID:    ID,
+		Changes:   changes,
+		RequestID: logging.RequestID(ctx),
+	})
+}
+
+// bookChanges diffs the JSON forms of two books field by field, leaving
+// out the bookkeeping fields every write touches. A nil before lists every
+// field of after.
+func bookChanges(before, after *domain.Book) map[string]domain.Change {
+	if after == nil {
+		return nil
+	}
+	old := bookFields(before)
+	changes := map[string]domain.Change{}
+	for field, value := range bookFields(after) {
+		switch field {
+		case "id", "created_at", "updated_at", "version", "deleted_at":
+			continue
+		}
+		if !reflect.DeepEqual(old[field], value) {
+			changes[field] = domain.Change{Before: old[field], After: value}
+		}
+	}
+	return changes
+}
+
+func bookFields(book *domain.Book) map[string]any {
+	fields := map[string]any{}
+	if book == nil {
+		return fields
+	}
+	// Books always encode.
+	data, _ := json.Marshal(book)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 500
+)
+
+// AuditService reads the audit log BookService writes.
+type AuditService struct {
+	repo   domain.AuditRepository
+	policy *Policy
+}
+
+func NewAuditService(repo domain.AuditRepository) *AuditService {
+	return &AuditService{repo: repo, policy: DefaultPolicy()}
+}
+
+// List returns one page of entries matching query, newest first.
+func (s *AuditService) List(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	if err := s.policy.Authorize(ctx, PermReadAudit); err != nil {
+		return domain.AuditPage{}, err
+	}
+
+	v := &validator{}
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultAuditPageSize
+	case query.Limit < 0 || query.Limit > MaxAuditPageSize:
+		v.add("limit", "must be between 1 and %d", MaxAuditPageSize)
+	}
+	if query.Action != "" && !slices.Contains(domain.AuditActions, query.Action) {
+		v.add("action", "must be one of %s", strings.Join(domain.AuditActions, ", "))
+	}
+	if query.Since != nil && query.Until != nil && !query.Since.Before(*query.Until) {
+		v.add("since", "must be before until")
+	}
+	if query.BookID < 0 {
+		v.add("book_id", "must not be negative")
+	}
+	if query.Before < 0 {
+		v.add("before", "must not be negative")
+	}
+	if err := v.err(); err != nil {
+		return domain.AuditPage{}, err
+	}
+
+	limit := query.Limit
+	query.Limit = limit + 1
+	entries, err := s.repo.ListAudit(ctx, query)
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+	page := domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextBefore = entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// History lists the entries for book ID.
+func (s *AuditService) History(ctx context.Context, ID int, query domain.AuditQuery) (domain.AuditPage, error) {
+	query.BookID = ID
+	return s.List(ctx, query)
+}
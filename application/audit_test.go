@@ -0,0 +1,227 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/logging"
+	"book-apis/mocks"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAuditedService() (*application.BookService, *mocks.MockBookRepository, *mocks.MockAuditRepository, *mocks.MockTransactor) {
+	repo, audits, tx := new(mocks.MockBookRepository), new(mocks.MockAuditRepository), new(mocks.MockTransactor)
+	return application.NewAuditedBookService(repo, audits, tx), repo, audits, tx
+}
+
+func TestBookService_AuditCreate(t *testing.T) {
+	service, repo, audits, tx := newAuditedService()
+	book := &domain.Book{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10}
+	created := *book
+	created.ID, created.Version = 7, 1
+	repo.On("CreateBook", book).Return(&created, nil)
+	audits.On("RecordAudit", &domain.AuditEntry{
+		Actor:  "user-1",
+		Action: domain.AuditCreate,
+		BookID: 7,
+		Changes: map[string]domain.Change{
+			"title":  {After: "Test Title 1"},
+			"author": {After: "Test Author 1"},
+			"genre":  {After: "Horror"},
+			"price":  {After: map[string]any{"amount": "100.00", "currency": "USD"}},
+			"stock":  {After: 10.0},
+			"isbn":   {After: ""},
+		},
+		RequestID: "req-1",
+	}).Return(nil)
+
+	ctx := logging.WithRequestID(as(domain.RoleAdmin), "req-1")
+	_, err := service.CreateBook(ctx, book)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, tx.Calls)
+	audits.AssertExpectations(t)
+}
+
+func TestBookService_AuditUpdate(t *testing.T) {
+	stored := domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 3}
+	updated := stored
+	updated.Price, updated.Version = domain.NewMoney(12000, "USD"), 4
+
+	type testCase struct {
+		name      string
+		version   int
+		mockSetup func(repo *mocks.MockBookRepository, audits *mocks.MockAuditRepository)
+		errIs     error
+		attempts  int
+	}
+	tests := []testCase{
+		{
+			name:    "success - pinned to the version diffed against",
+			version: 0,
+			mockSetup: func(repo *mocks.MockBookRepository, audits *mocks.MockAuditRepository) {
+				repo.On("GetBook", 1).Return(stored, nil)
+				repo.On("UpdateBook", mock.MatchedBy(func(b *domain.Book) bool { return b.Version == 3 }), 1).Return(&updated, nil)
+				audits.On("RecordAudit", mock.MatchedBy(func(e *domain.AuditEntry) bool {
+					return e.Actor == "system" && e.Action == domain.AuditUpdate && len(e.Changes) == 1 &&
+						assert.ObjectsAreEqual(domain.Change{
+							Before: map[string]any{"amount": "100.00", "currency": "USD"},
+							After:  map[string]any{"amount": "120.00", "currency": "USD"},
+						}, e.Changes["price"])
+				})).Return(nil)
+			},
+			attempts: 1,
+		},
+		{
+			name:    "success - retried when another write got in first",
+			version: 0,
+			mockSetup: func(repo *mocks.MockBookRepository, audits *mocks.MockAuditRepository) {
+				repo.On("GetBook", 1).Return(stored, nil)
+				repo.On("UpdateBook", mock.Anything, 1).Return(nil, &domain.StaleError{Resource: "book", ID: 1}).Once()
+				repo.On("UpdateBook", mock.Anything, 1).Return(&updated, nil).Once()
+				audits.On("RecordAudit", mock.Anything).Return(nil).Once()
+			},
+			attempts: 2,
+		},
+		{
+			name:    "not success - stale expected version is not retried",
+			version: 2,
+			mockSetup: func(repo *mocks.MockBookRepository, audits *mocks.MockAuditRepository) {
+				repo.On("GetBook", 1).Return(stored, nil)
+			},
+			errIs:    domain.ErrStale,
+			attempts: 1,
+		},
+		{
+			name:    "not success - audit write fails",
+			version: 3,
+			mockSetup: func(repo *mocks.MockBookRepository, audits *mocks.MockAuditRepository) {
+				repo.On("GetBook", 1).Return(stored, nil)
+				repo.On("UpdateBook", mock.Anything, 1).Return(&updated, nil)
+				audits.On("RecordAudit", mock.Anything).Return(&domain.UnavailableError{})
+			},
+			errIs:    domain.ErrUnavailable,
+			attempts: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			service, repo, audits, tx := newAuditedService()
+			tc.mockSetup(repo, audits)
+
+			book := stored
+			book.Price, book.Version = domain.NewMoney(12000, "USD"), tc.version
+			result, err := service.UpdateBook(context.Background(), &book, 1)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, &updated, result)
+			}
+			assert.Equal(t, tc.attempts, tx.Calls)
+			repo.AssertExpectations(t)
+			audits.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBookService_AuditDeleteAndRestore(t *testing.T) {
+	service, repo, audits, _ := newAuditedService()
+	repo.On("GetBook", 1).Return(domain.Book{ID: 1, Version: 3}, nil)
+	repo.On("DeleteBook", 1, 3).Return(nil)
+	repo.On("RestoreBook", 1).Return(&domain.Book{ID: 1, Version: 5}, nil)
+	audits.On("RecordAudit", &domain.AuditEntry{Actor: "system", Action: domain.AuditDelete, BookID: 1, Changes: map[string]domain.Change{"deleted": {Before: false, After: true}}}).Return(nil)
+	audits.On("RecordAudit", &domain.AuditEntry{Actor: "system", Action: domain.AuditRestore, BookID: 1, Changes: map[string]domain.Change{"deleted": {Before: true, After: false}}}).Return(nil)
+
+	assert.NoError(t, service.DeleteBook(context.Background(), 1, 3))
+	_, err := service.RestoreBook(context.Background(), 1)
+	assert.NoError(t, err)
+	audits.AssertExpectations(t)
+}
+
+func TestBookService_AuditSkipsEmptyPatch(t *testing.T) {
+	service, repo, audits, _ := newAuditedService()
+	repo.On("GetBook", 1).Return(domain.Book{ID: 1, Version: 3}, nil)
+
+	_, err := service.EditBook(context.Background(), 1, 0, func(book domain.Book) (domain.Book, error) { return book, nil })
+	assert.NoError(t, err)
+	audits.AssertNotCalled(t, "RecordAudit", mock.Anything)
+}
+
+func TestAuditService_List(t *testing.T) {
+	errDB := errors.New("Some DB error")
+	type testCase struct {
+		name      string
+		ctx       context.Context
+		query     domain.AuditQuery
+		expected  domain.AuditPage
+		mockSetup func(audits *mocks.MockAuditRepository)
+		errIs     error
+	}
+	tests := []testCase{
+		{
+			name:  "success - next page",
+			ctx:   as(domain.RoleInventoryManager),
+			query: domain.AuditQuery{Limit: 2, Actor: "user-1"},
+			mockSetup: func(audits *mocks.MockAuditRepository) {
+				audits.On("ListAudit", domain.AuditQuery{Limit: 3, Actor: "user-1"}).Return([]domain.AuditEntry{{ID: 9}, {ID: 8}, {ID: 5}}, nil)
+			},
+			expected: domain.AuditPage{Entries: []domain.AuditEntry{{ID: 9}, {ID: 8}}, NextBefore: 8},
+		},
+		{
+			name: "success - default limit",
+			ctx:  as(domain.RoleAdmin),
+			mockSetup: func(audits *mocks.MockAuditRepository) {
+				audits.On("ListAudit", domain.AuditQuery{Limit: application.DefaultAuditPageSize + 1}).Return([]domain.AuditEntry{{ID: 1}}, nil)
+			},
+			expected: domain.AuditPage{Entries: []domain.AuditEntry{{ID: 1}}},
+		},
+		{
+			name:      "not success - viewer",
+			ctx:       as(domain.RoleViewer),
+			mockSetup: func(audits *mocks.MockAuditRepository) {},
+			errIs:     domain.ErrForbidden,
+		},
+		{
+			name:      "not success - bad action",
+			ctx:       as(domain.RoleAdmin),
+			query:     domain.AuditQuery{Action: "archive"},
+			mockSetup: func(audits *mocks.MockAuditRepository) {},
+			errIs:     domain.ErrValidation,
+		},
+		{
+			name:      "not success - limit too large",
+			ctx:       as(domain.RoleAdmin),
+			query:     domain.AuditQuery{Limit: application.MaxAuditPageSize + 1},
+			mockSetup: func(audits *mocks.MockAuditRepository) {},
+			errIs:     domain.ErrValidation,
+		},
+		{
+			name: "not success - repository error",
+			ctx:  as(domain.RoleAdmin),
+			mockSetup: func(audits *mocks.MockAuditRepository) {
+				audits.On("ListAudit", mock.Anything).Return([]domain.AuditEntry(nil), errDB)
+			},
+			errIs: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			audits := new(mocks.MockAuditRepository)
+			tc.mockSetup(audits)
+			page, err := application.NewAuditService(audits).List(tc.ctx, tc.query)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, page)
+			}
+			audits.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package application
+
+import (
+	"book-apis/domain"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// APIKeyPrefix starts every API key secret, which tells them apart from
+// JWTs and makes leaked keys easy to scan for.
+const APIKeyPrefix = "bk_"
+
+const (
+	MaxAPIKeyNameLength = 100
+	// apiKeyPrefixLength is how much of a secret is stored in the clear.
+	apiKeyPrefixLength = len(APIKeyPrefix) + 8
+)
+
+type AuthService struct {
+	keys   domain.APIKeyRepository
+	tokens domain.TokenVerifier
+	policy *Policy
+}
+
+// NewAuthService authenticates API keys from keys and JWTs with tokens.
+// A nil tokens rejects every JWT.
+func NewAuthService(keys domain.APIKeyRepository, tokens domain.TokenVerifier) *AuthService {
+	return &AuthService{keys: keys, tokens: tokens, policy: DefaultPolicy()}
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the principal a credential belongs to: an API key
+// when it carries APIKeyPrefix, a JWT otherwise. Subjects are prefixed with
+// how the caller signed in, so a token's sub claim can never pass for an
+// API key.
+func (s *AuthService) Authenticate(ctx context.Context, credential string) (domain.Principal, error) {
+	if !strings.HasPrefix(credential, APIKeyPrefix) {
+		if s.tokens == nil {
+			return domain.Principal{}, &domain.UnauthorizedError{Message: "bearer tokens are not accepted"}
+		}
+		principal, err := s.tokens.VerifyToken(ctx, credential)
+		if err != nil {
+			return domain.Principal{}, err
+		}
+		principal.Subject = "jwt:" + principal.Subject
+		return principal, nil
+	}
+
+	key, err := s.keys.FindAPIKey(ctx, hashAPIKey(credential))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, &domain.UnauthorizedError{Message: "unknown api key"}
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+	if key.RevokedAt != nil {
+		return domain.Principal{}, &domain.UnauthorizedError{Message: "api key has been revoked"}
+	}
+	return domain.Principal{Subject: "api_key:" + strconv.Itoa(key.ID), Name: key.Name, Method: "api_key", Roles: key.Roles}, nil
+}
+
+// IssueAPIKey creates a key and returns it with its secret. The secret is
+// not stored and cannot be recovered later.
+func (s *AuthService) IssueAPIKey(ctx context.Context, name string, roles []string) (*domain.APIKey, string, error) {
+	if err := s.policy.Authorize(ctx, PermManageKeys); err != nil {
+		return nil, "", err
+	}
+	v := &validator{}
+	v.text("name", name, true, MaxAPIKeyNameLength)
+	for _, role := range roles {
+		if !slices.Contains(domain.Roles, role) {
+			v.add("roles", "%q is not one of %s", role, strings.Join(domain.Roles, ", "))
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	secret := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	key, err := s.keys.CreateAPIKey(ctx, &domain.APIKey{
+		Name:   strings.TrimSpace(name),
+		Prefix: secret[:apiKeyPrefixLength],
+		Hash:   hashAPIKey(secret),
+		Roles:  roles,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	if err := s.policy.Authorize(ctx, PermManageKeys); err != nil {
+		return nil, err
+	}
+	return s.keys.ListAPIKeys(ctx)
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, ID int) error {
+	if err := s.policy.Authorize(ctx, PermManageKeys); err != nil {
+		return err
+	}
+	return s.keys.RevokeAPIKey(ctx, ID)
+}
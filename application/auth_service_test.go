@@ -0,0 +1,162 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/mocks"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func hashOf(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAuthService_Authenticate(t *testing.T) {
+	revokedAt := time.Now()
+	type testCase struct {
+		name       string
+		credential string
+		expected   domain.Principal
+		mockSetup  func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier)
+		errIs      error
+	}
+	tests := []testCase{
+		{
+			name:       "success - api key",
+			credential: "bk_secret",
+			expected:   domain.Principal{Subject: "api_key:3", Name: "ci", Method: "api_key", Roles: []string{"clerk"}},
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				keys.On("FindAPIKey", hashOf("bk_secret")).Return(domain.APIKey{ID: 3, Name: "ci", Roles: []string{"clerk"}}, nil)
+			},
+		},
+		{
+			name:       "not success - unknown api key",
+			credential: "bk_secret",
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				keys.On("FindAPIKey", hashOf("bk_secret")).Return(domain.APIKey{}, &domain.NotFoundError{Resource: "api key"})
+			},
+			errIs: domain.ErrUnauthorized,
+		},
+		{
+			name:       "not success - revoked api key",
+			credential: "bk_secret",
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				keys.On("FindAPIKey", hashOf("bk_secret")).Return(domain.APIKey{ID: 3, RevokedAt: &revokedAt}, nil)
+			},
+			errIs: domain.ErrUnauthorized,
+		},
+		{
+			name:       "not success - key store down",
+			credential: "bk_secret",
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				keys.On("FindAPIKey", hashOf("bk_secret")).Return(domain.APIKey{}, &domain.UnavailableError{})
+			},
+			errIs: domain.ErrUnavailable,
+		},
+		{
+			name:       "success - jwt",
+			credential: "eyJhbGciOi",
+			expected:   domain.Principal{Subject: "jwt:user-1", Method: "jwt"},
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				tokens.On("VerifyToken", "eyJhbGciOi").Return(domain.Principal{Subject: "user-1", Method: "jwt"}, nil)
+			},
+		},
+		{
+			name:       "success - jwt can not pass for an api key",
+			credential: "eyJhbGciOi",
+			expected:   domain.Principal{Subject: "jwt:api_key:1", Method: "jwt"},
+			mockSetup: func(keys *mocks.MockAPIKeyRepository, tokens *mocks.MockTokenVerifier) {
+				tokens.On("VerifyToken", "eyJhbGciOi").Return(domain.Principal{Subject: "api_key:1", Method: "jwt"}, nil)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, tokens := new(mocks.MockAPIKeyRepository), new(mocks.MockTokenVerifier)
+			tc.mockSetup(keys, tokens)
+			service := application.NewAuthService(keys, tokens)
+
+			principal, err := service.Authenticate(context.Background(), tc.credential)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, principal)
+			}
+			keys.AssertExpectations(t)
+			tokens.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_AuthenticateWithoutKeySet(t *testing.T) {
+	service := application.NewAuthService(new(mocks.MockAPIKeyRepository), nil)
+	_, err := service.Authenticate(context.Background(), "eyJhbGciOi")
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func TestAuthService_IssueAPIKey(t *testing.T) {
+	keys := new(mocks.MockAPIKeyRepository)
+	var stored *domain.APIKey
+	keys.On("CreateAPIKey", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*domain.APIKey)
+	}).Return(&domain.APIKey{ID: 1, Name: "ci"}, nil)
+	service := application.NewAuthService(keys, nil)
+
+	key, secret, err := service.IssueAPIKey(context.Background(), " ci ", []string{"clerk"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, key.ID)
+	assert.True(t, strings.HasPrefix(secret, application.APIKeyPrefix))
+	assert.Len(t, secret, len(application.APIKeyPrefix)+43)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, "ci", stored.Name)
+		assert.Equal(t, hashOf(secret), stored.Hash)
+		assert.Equal(t, secret[:11], stored.Prefix)
+		assert.NotContains(t, stored.Hash, secret)
+	}
+}
+
+func TestAuthService_KeyManagementPermissions(t *testing.T) {
+	admin := domain.WithPrincipal(context.Background(), domain.Principal{Subject: "user-1", Roles: []string{domain.RoleAdmin}})
+	clerk := domain.WithPrincipal(context.Background(), domain.Principal{Subject: "user-2", Roles: []string{"clerk"}})
+
+	type testCase struct {
+		name  string
+		ctx   context.Context
+		errIs error
+	}
+	tests := []testCase{
+		{name: "admin", ctx: admin},
+		{name: "internal call", ctx: context.Background()},
+		{name: "clerk", ctx: clerk, errIs: domain.ErrForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := new(mocks.MockAPIKeyRepository)
+			keys.On("ListAPIKeys").Return([]domain.APIKey{}, nil).Maybe()
+			keys.On("RevokeAPIKey", 1).Return(nil).Maybe()
+			service := application.NewAuthService(keys, nil)
+
+			_, err := service.ListAPIKeys(tc.ctx)
+			assert.ErrorIs(t, err, tc.errIs)
+			assert.ErrorIs(t, service.RevokeAPIKey(tc.ctx, 1), tc.errIs)
+			_, _, err = service.IssueAPIKey(tc.ctx, "", nil)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+			} else {
+				assert.ErrorIs(t, err, domain.ErrValidation)
+			}
+		})
+	}
+}
@@ -1,31 +1,249 @@
 package application
 
-import "book-apis/domain"
+import (
+	"book-apis/domain"
+	"context"
+	"strings"
+)
 
 type BookService struct {
 	service domain.BookRepository
+	policy  *Policy
+	audit   domain.AuditRepository
+	tx      domain.Transactor
 }
 
+// NewBookService checks writes against DefaultPolicy and keeps no audit
+// log.
 func NewBookService(repo domain.BookRepository) *BookService {
-	return &BookService{service: repo}
+	return &BookService{service: repo, policy: DefaultPolicy()}
 }
 
-func (s *BookService) GetAll() ([]domain.Book, error) {
-	return s.service.GetAll()
+// NewAuditedBookService records every write in audit, in a transaction
+// from tx shared with the write itself.
+func NewAuditedBookService(repo domain.BookRepository, audit domain.AuditRepository, tx domain.Transactor) *BookService {
+	return &BookService{service: repo, policy: DefaultPolicy(), audit: audit, tx: tx}
 }
 
-func (s *BookService) GetBook(ID int) (domain.Book, error) {
-	return s.service.GetBook(ID)
+// GetAll returns one page of books. It asks the repository for one extra
+// row to learn whether a next page exists.
+func (s *BookService) GetAll(ctx context.Context, query domain.BookQuery) (page domain.BookPage, err error) {
+	ctx, span := startSpan(ctx, "GetAll")
+	defer func() { endSpan(span, err) }()
+
+	if err := normalizeQuery(&query); err != nil {
+		return domain.BookPage{}, err
+	}
+
+	limit := query.Limit
+	query.Limit = limit + 1
+	books, err := s.service.GetAll(ctx, query)
+	if err != nil {
+		return domain.BookPage{}, err
+	}
+
+	page = domain.BookPage{Books: books}
+	if len(books) > limit {
+		page.Books = books[:limit]
+		page.NextCursor = encodeCursor(query, &books[limit-1])
+	}
+	return page, nil
 }
 
-func (s *BookService) CreateBook(book *domain.Book) (*domain.Book, error) {
-	return s.service.CreateBook(book)
+// Trash returns one page of deleted books that have not been purged yet.
+func (s *BookService) Trash(ctx context.Context, query domain.BookQuery) (page domain.BookPage, err error) {
+	ctx, span := startSpan(ctx, "Trash")
+	defer func() { endSpan(span, err) }()
+
+	query.Deleted = true
+	return s.GetAll(ctx, query)
+}
+
+func (s *BookService) GetBook(ctx context.Context, ID int) (book domain.Book, err error) {
+	ctx, span := startSpan(ctx, "GetBook")
+	defer func() { endSpan(span, err) }()
+
+	return s.service.GetBook(ctx, ID)
+}
+
+func (s *BookService) CreateBook(ctx context.Context, book *domain.Book) (created *domain.Book, err error) {
+	ctx, span := startSpan(ctx, "CreateBook")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.policy.Authorize(ctx, PermCreateBook); err != nil {
+		return nil, err
+	}
+	if book == nil {
+		return nil, &domain.ValidationError{Message: "book is required"}
+	}
+	if err := ValidateBook(book); err != nil {
+		return nil, err
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if created, err = s.service.CreateBook(ctx, book); err != nil {
+			return err
+		}
+		return s.record(ctx, domain.AuditCreate, created.ID, bookChanges(nil, created))
+	})
+	return created, err
+}
+
+// UpdateBook replaces book ID. book.Version is the version the stored book
+// must still be at, or 0 to overwrite whatever is there.
+func (s *BookService) UpdateBook(ctx context.Context, book *domain.Book, ID int) (updated *domain.Book, err error) {
+	ctx, span := startSpan(ctx, "UpdateBook")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.policy.Authorize(ctx, PermUpdateStock); err != nil {
+		return nil, err
+	}
+	if book == nil {
+		return nil, &domain.ValidationError{Message: "book is required"}
+	}
+	version := book.Version
+	err = s.retryUnpinned(ctx, version, func(ctx context.Context) error {
+		book.Version = version
+		if err := s.authorizeUpdate(ctx, book, ID); err != nil {
+			return err
+		}
+		if err := ValidateBook(book); err != nil {
+			return err
+		}
+		before, err := s.snapshot(ctx, ID, &book.Version)
+		if err != nil {
+			return err
+		}
+		if updated, err = s.service.UpdateBook(ctx, book, ID); err != nil {
+			return err
+		}
+		return s.record(ctx, domain.AuditUpdate, ID, bookChanges(before, updated))
+	})
+	return updated, err
 }
 
-func (s *BookService) UpdateBook(book *domain.Book, ID int) (*domain.Book, error) {
-	return s.service.UpdateBook(book, ID)
+// authorizeUpdate checks a replacement of book ID made by a caller who may
+// only adjust stock against the stored book. The write is then pinned to
+// the version checked so it cannot undo a concurrent price change.
+func (s *BookService) authorizeUpdate(ctx context.Context, book *domain.Book, ID int) error {
+	if s.policy.allowed(ctx, PermUpdateBook) {
+		return nil
+	}
+	current, err := s.service.GetBook(ctx, ID)
+	if err != nil {
+		return err
+	}
+	if book.Version != 0 && book.Version != current.Version {
+		return &domain.StaleError{Resource: "book", ID: ID}
+	}
+	if err := s.policy.AuthorizeUpdate(ctx, domain.DiffBook(&current, book)); err != nil {
+		return err
+	}
+	book.Version = current.Version
+	return nil
 }
 
-func (s *BookService) DeleteBook(ID int) error {
-	return s.service.DeleteBook(ID)
+// EditBook reads book ID, has edit change it and writes the fields that
+// changed, pinned to the version that was read. version is the version the
+// stored book must still be at, or 0 for any; in that case the whole
+// read, edit and write is retried when another write lands in between, so
+// an edit that depends on the stored book never acts on a stale copy.
+func (s *BookService) EditBook(ctx context.Context, ID int, version int, edit func(book domain.Book) (domain.Book, error)) (edited *domain.Book, err error) {
+	ctx, span := startSpan(ctx, "EditBook")
+	defer func() { endSpan(span, err) }()
+
+	err = s.retryStale(ctx, version, func(ctx context.Context) error {
+		before, err := s.service.GetBook(ctx, ID)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != before.Version {
+			return &domain.StaleError{Resource: "book", ID: ID}
+		}
+		after, err := edit(before)
+		if err != nil {
+			return err
+		}
+		patch := domain.DiffBook(&before, &after)
+		if err := s.policy.AuthorizeUpdate(ctx, patch); err != nil {
+			return err
+		}
+		if patch.IsEmpty() {
+			edited = &before
+			return nil
+		}
+		book := before
+		patch.Apply(&book)
+		if err := ValidateBook(&book); err != nil {
+			return err
+		}
+
+		patch.Version = before.Version
+		if edited, err = s.service.PatchBook(ctx, patch, ID); err != nil {
+			return err
+		}
+		return s.record(ctx, domain.AuditUpdate, ID, bookChanges(&before, edited))
+	})
+	return edited, err
+}
+
+// DeleteBook moves book ID to the trash if it is still at version, or at
+// any version when version is 0.
+func (s *BookService) DeleteBook(ctx context.Context, ID int, version int) (err error) {
+	ctx, span := startSpan(ctx, "DeleteBook")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.policy.Authorize(ctx, PermDeleteBook); err != nil {
+		return err
+	}
+	return s.retryUnpinned(ctx, version, func(ctx context.Context) error {
+		expected := version
+		if _, err := s.snapshot(ctx, ID, &expected); err != nil {
+			return err
+		}
+		if err := s.service.DeleteBook(ctx, ID, expected); err != nil {
+			return err
+		}
+		return s.record(ctx, domain.AuditDelete, ID, map[string]domain.Change{"deleted": {Before: false, After: true}})
+	})
+}
+
+func (s *BookService) RestoreBook(ctx context.Context, ID int) (restored *domain.Book, err error) {
+	ctx, span := startSpan(ctx, "RestoreBook")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.policy.Authorize(ctx, PermDeleteBook); err != nil {
+		return nil, err
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if restored, err = s.service.RestoreBook(ctx, ID); err != nil {
+			return err
+		}
+		return s.record(ctx, domain.AuditRestore, ID, map[string]domain.Change{"deleted": {Before: true, After: false}})
+	})
+	return restored, err
+}
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+func (s *BookService) Search(ctx context.Context, query string, limit int) (results []domain.SearchResult, err error) {
+	ctx, span := startSpan(ctx, "Search")
+	defer func() { endSpan(span, err) }()
+
+	v := &validator{}
+	if strings.TrimSpace(query) == "" {
+		v.add("q", "is required")
+	}
+	switch {
+	case limit == 0:
+		limit = DefaultSearchLimit
+	case limit < 0 || limit > MaxSearchLimit:
+		v.add("limit", "must be between 1 and %d", MaxSearchLimit)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return s.service.Search(ctx, query, limit)
 }
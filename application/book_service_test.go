@@ -4,10 +4,12 @@ import (
 	"book-apis/application"
 	"book-apis/domain"
 	"book-apis/mocks"
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestBookService_GetAll(t *testing.T) {
@@ -20,13 +22,13 @@ func TestBookService_GetAll(t *testing.T) {
 		{
 			name: "Successful Retrieval",
 			expected: []domain.Book{
-				{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10},
-				{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: "150", Stock: 20},
+				{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10},
+				{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20},
 			},
 			mockSetup: func(mockRepo *mocks.MockBookRepository) {
-				mockRepo.On("GetAll").Return([]domain.Book{
-					{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10},
-					{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: "150", Stock: 20},
+				mockRepo.On("GetAll", domain.BookQuery{Limit: application.DefaultPageSize + 1, Sort: domain.SortByID}).Return([]domain.Book{
+					{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10},
+					{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20},
 				}, nil)
 			},
 		},
@@ -34,14 +36,14 @@ func TestBookService_GetAll(t *testing.T) {
 			name:     "Empty List",
 			expected: []domain.Book{},
 			mockSetup: func(mockRepo *mocks.MockBookRepository) {
-				mockRepo.On("GetAll").Return([]domain.Book{}, nil)
+				mockRepo.On("GetAll", domain.BookQuery{Limit: application.DefaultPageSize + 1, Sort: domain.SortByID}).Return([]domain.Book{}, nil)
 			},
 		},
 		{
 			name:     "Error Retrieval",
 			expected: nil,
 			mockSetup: func(mockRepo *mocks.MockBookRepository) {
-				mockRepo.On("GetAll").Return([]domain.Book(nil), assert.AnError)
+				mockRepo.On("GetAll", domain.BookQuery{Limit: application.DefaultPageSize + 1, Sort: domain.SortByID}).Return([]domain.Book(nil), assert.AnError)
 			},
 		},
 	}
@@ -54,14 +56,15 @@ func TestBookService_GetAll(t *testing.T) {
 
 			service := application.NewBookService(mockRepo)
 
-			result, err := service.GetAll()
+			result, err := service.GetAll(context.Background(), domain.BookQuery{})
 
 			if tc.expected != nil {
 				assert.NoError(t, err)
-				assert.Equal(t, tc.expected, result)
+				assert.Equal(t, tc.expected, result.Books)
+				assert.Empty(t, result.NextCursor)
 			} else {
 				assert.Error(t, err)
-				assert.Nil(t, result)
+				assert.Nil(t, result.Books)
 			}
 
 			mockRepo.AssertExpectations(t)
@@ -69,6 +72,68 @@ func TestBookService_GetAll(t *testing.T) {
 	}
 }
 
+func TestBookService_GetAllPagination(t *testing.T) {
+	mockRepo := new(mocks.MockBookRepository)
+	service := application.NewBookService(mockRepo)
+
+	books := []domain.Book{
+		{ID: 3, Title: "Test Title 3", Price: domain.NewMoney(100, "USD")},
+		{ID: 1, Title: "Test Title 1", Price: domain.NewMoney(200, "USD")},
+		{ID: 2, Title: "Test Title 2", Price: domain.NewMoney(300, "USD")},
+	}
+	mockRepo.On("GetAll", domain.BookQuery{Limit: 3, Sort: domain.SortByPrice, Currency: "USD"}).Return(books, nil).Once()
+
+	page, err := service.GetAll(context.Background(), domain.BookQuery{Limit: 2, Sort: domain.SortByPrice, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, books[:2], page.Books)
+	assert.NotEmpty(t, page.NextCursor)
+
+	after := &domain.Book{ID: 1, Price: domain.NewMoney(200, "")}
+	mockRepo.On("GetAll", domain.BookQuery{Limit: 3, Sort: domain.SortByPrice, Currency: "USD", Cursor: page.NextCursor, After: after}).Return(books[2:], nil).Once()
+
+	page, err = service.GetAll(context.Background(), domain.BookQuery{Limit: 2, Sort: domain.SortByPrice, Currency: "USD", Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, books[2:], page.Books)
+	assert.Empty(t, page.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBookService_GetAllInvalidQuery(t *testing.T) {
+	mockRepo := new(mocks.MockBookRepository)
+	service := application.NewBookService(mockRepo)
+	low, high := int64(500), int64(100)
+
+	type testCase struct {
+		name     string
+		query    domain.BookQuery
+		expected []domain.FieldError
+	}
+	tests := []testCase{
+		{name: "limit too large", query: domain.BookQuery{Limit: 1000}, expected: []domain.FieldError{{Field: "limit", Message: "must be between 1 and 200"}}},
+		{name: "unknown sort", query: domain.BookQuery{Sort: "stock"}, expected: []domain.FieldError{{Field: "sort", Message: "must be one of [id title author price created_at]"}}},
+		{name: "price sort without currency", query: domain.BookQuery{Sort: domain.SortByPrice}, expected: []domain.FieldError{{Field: "currency", Message: "is required when sorting by price"}}},
+		{name: "garbage cursor", query: domain.BookQuery{Cursor: "???"}, expected: []domain.FieldError{{Field: "cursor", Message: "is invalid for this sort order"}}},
+		{name: "price range", query: domain.BookQuery{MinPrice: &low, MaxPrice: &high}, expected: []domain.FieldError{{Field: "max_price", Message: "must not be less than min_price"}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.GetAll(context.Background(), tc.query)
+			var validationErr *domain.ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, tc.expected, validationErr.Fields)
+			}
+		})
+	}
+
+	// A cursor issued for one sort order is rejected for another.
+	mockRepo.On("GetAll", mock.Anything).Return([]domain.Book{{ID: 1}, {ID: 2}}, nil).Once()
+	page, err := service.GetAll(context.Background(), domain.BookQuery{Limit: 1, Sort: domain.SortByTitle})
+	assert.NoError(t, err)
+	_, err = service.GetAll(context.Background(), domain.BookQuery{Limit: 1, Sort: domain.SortByAuthor, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestBookService_GetOneBook(t *testing.T) {
 	type testCase struct {
 		name      string
@@ -81,11 +146,11 @@ func TestBookService_GetOneBook(t *testing.T) {
 			name: "Successful retrival one book",
 			ID:   1,
 			expected: domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func(mockRepo *mocks.MockBookRepository) {
 				mockRepo.On("GetBook", 1).Return(domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 		},
@@ -103,7 +168,7 @@ func TestBookService_GetOneBook(t *testing.T) {
 			mockRepo := new(mocks.MockBookRepository)
 			tc.mockSetup(mockRepo)
 			service := application.NewBookService(mockRepo)
-			result, err := service.GetBook(tc.ID)
+			result, err := service.GetBook(context.Background(), tc.ID)
 			if tc.expected != (domain.Book{}) {
 				assert.NoError(t, err)
 				assert.Equal(t, tc.expected, result)
@@ -129,33 +194,43 @@ func TestBookService_CreateBook(t *testing.T) {
 		{
 			name: "Successful book create",
 			input: &domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			expected: &domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
 				mockRepo.On("CreateBook", &domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}).Return(&domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 		},
 		{
-			name:     "Un Successful book create",
-			input:    &domain.Book{},
+			name: "Un Successful book create",
+			input: &domain.Book{
+				Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20,
+			},
 			expected: nil,
 			mockSetup: func() {
-				mockRepo.On("CreateBook", &domain.Book{}).Return(nil, errors.New("Ohh no error!"))
+				mockRepo.On("CreateBook", &domain.Book{
+					Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20,
+				}).Return(nil, errors.New("Ohh no error!"))
 			},
 		},
+		{
+			name:      "Invalid book",
+			input:     &domain.Book{},
+			expected:  nil,
+			mockSetup: func() {},
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
-			result, err := service.CreateBook(tc.input)
+			result, err := service.CreateBook(context.Background(), tc.input)
 			if err != nil {
 				assert.Error(t, err)
 				assert.Nil(t, result)
@@ -184,34 +259,45 @@ func TestBookService_UpdateBook(t *testing.T) {
 			name: "Successful book update",
 			ID:   1,
 			input: &domain.Book{
-				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			expected: &domain.Book{
-				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
 				mock.On("UpdateBook", &domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, 1).Return(&domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 		},
 		{
-			name:     "Unsuccessful book update",
-			ID:       1,
-			input:    nil,
+			name: "Unsuccessful book update",
+			ID:   2,
+			input: &domain.Book{
+				Title: "Updated Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20,
+			},
 			expected: nil,
 			mockSetup: func() {
-				mock.On("UpdateBook", (*domain.Book)(nil), 1).Return(nil, errors.New("Oh no error!!"))
+				mock.On("UpdateBook", &domain.Book{
+					Title: "Updated Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20,
+				}, 2).Return(nil, &domain.NotFoundError{Resource: "book", ID: 2})
 			},
 		},
+		{
+			name:      "Missing book body",
+			ID:        1,
+			input:     nil,
+			expected:  nil,
+			mockSetup: func() {},
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
-			result, err := service.UpdateBook(tc.input, tc.ID)
+			result, err := service.UpdateBook(context.Background(), tc.input, tc.ID)
 			if err != nil {
 				assert.Error(t, err)
 				assert.Nil(t, result)
@@ -223,6 +309,112 @@ func TestBookService_UpdateBook(t *testing.T) {
 	}
 }
 
+func TestBookService_EditBook(t *testing.T) {
+	v1 := domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 1}
+	v2 := v1
+	v2.Stock, v2.Version = 5, 2
+	nine, four := 9, 4
+	errEdit := errors.New("Some edit error")
+	sell := func(book domain.Book) (domain.Book, error) {
+		book.Stock--
+		return book, nil
+	}
+
+	type testCase struct {
+		name      string
+		version   int
+		edit      func(book domain.Book) (domain.Book, error)
+		expected  *domain.Book
+		mockSetup func(mock *mocks.MockBookRepository)
+		errIs     error
+	}
+
+	tests := []testCase{
+		{
+			name:     "Write is pinned to the version read",
+			edit:     sell,
+			expected: &domain.Book{ID: 1, Stock: 9, Version: 2},
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v1, nil)
+				mock.On("PatchBook", domain.BookPatch{Stock: &nine, Version: 1}, 1).Return(&domain.Book{ID: 1, Stock: 9, Version: 2}, nil)
+			},
+		},
+		{
+			name:     "Stale write is edited again from the new version",
+			edit:     sell,
+			expected: &domain.Book{ID: 1, Stock: 4, Version: 3},
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v1, nil).Once()
+				mock.On("PatchBook", domain.BookPatch{Stock: &nine, Version: 1}, 1).Return(nil, &domain.StaleError{Resource: "book", ID: 1})
+				mock.On("GetBook", 1).Return(v2, nil).Once()
+				mock.On("PatchBook", domain.BookPatch{Stock: &four, Version: 2}, 1).Return(&domain.Book{ID: 1, Stock: 4, Version: 3}, nil)
+			},
+		},
+		{
+			name:    "Expected version is not retried",
+			version: 1,
+			edit:    sell,
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v2, nil)
+			},
+			errIs: domain.ErrStale,
+		},
+		{
+			name:     "Unchanged book is returned",
+			edit:     func(book domain.Book) (domain.Book, error) { return book, nil },
+			expected: &v1,
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v1, nil)
+			},
+		},
+		{
+			name: "Edited book is invalid",
+			edit: func(book domain.Book) (domain.Book, error) {
+				book.Stock = -1
+				return book, nil
+			},
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v1, nil)
+			},
+			errIs: domain.ErrValidation,
+		},
+		{
+			name: "Missing book",
+			edit: sell,
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(domain.Book{}, &domain.NotFoundError{Resource: "book", ID: 1})
+			},
+			errIs: domain.ErrNotFound,
+		},
+		{
+			name: "Edit fails",
+			edit: func(book domain.Book) (domain.Book, error) { return domain.Book{}, errEdit },
+			mockSetup: func(mock *mocks.MockBookRepository) {
+				mock.On("GetBook", 1).Return(v1, nil)
+			},
+			errIs: errEdit,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := new(mocks.MockBookRepository)
+			service := application.NewBookService(mock)
+			tc.mockSetup(mock)
+
+			result, err := service.EditBook(context.Background(), 1, tc.version, tc.edit)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, result)
+			}
+			mock.AssertExpectations(t)
+		})
+	}
+}
+
 func TestBookService_deleteBook(t *testing.T) {
 	mock := new(mocks.MockBookRepository)
 	service := application.NewBookService(mock)
@@ -239,7 +431,7 @@ func TestBookService_deleteBook(t *testing.T) {
 			ID:       1,
 			expected: nil,
 			mockSetup: func() {
-				mock.On("DeleteBook", 1).Return(nil)
+				mock.On("DeleteBook", 1, 0).Return(nil)
 			},
 		},
 		{
@@ -247,13 +439,13 @@ func TestBookService_deleteBook(t *testing.T) {
 			ID:       2,
 			expected: "Oh no error!!",
 			mockSetup: func() {
-				mock.On("DeleteBook", 2).Return(errors.New("Oh no error!"))
+				mock.On("DeleteBook", 2, 0).Return(errors.New("Oh no error!"))
 			},
 		},
 	}
 	for _, tc := range tests {
 		tc.mockSetup()
-		err := service.DeleteBook(tc.ID)
+		err := service.DeleteBook(context.Background(), tc.ID, 0)
 		if err != nil {
 			assert.Error(t, err)
 		} else {
@@ -262,3 +454,24 @@ func TestBookService_deleteBook(t *testing.T) {
 		}
 	}
 }
+
+func TestBookService_Search(t *testing.T) {
+	mockRepo := new(mocks.MockBookRepository)
+	service := application.NewBookService(mockRepo)
+
+	expected := []domain.SearchResult{{Book: domain.Book{ID: 1, Title: "Test Title 1"}, Score: 1}}
+	mockRepo.On("Search", "test", application.DefaultSearchLimit).Return(expected, nil).Once()
+	results, err := service.Search(context.Background(), "test", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, results)
+
+	_, err = service.Search(context.Background(), " ", 500)
+	var validationErr *domain.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, []domain.FieldError{
+			{Field: "q", Message: "is required"},
+			{Field: "limit", Message: "must be between 1 and 100"},
+		}, validationErr.Fields)
+	}
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"book-apis/domain"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	ID    int    `json:"i"`
+	Value string `json:"v,omitempty"`
+}
+
+func encodeCursor(query domain.BookQuery, last *domain.Book) string {
+	c := cursor{Sort: query.Sort, Desc: query.Desc, ID: last.ID}
+	switch query.Sort {
+	case domain.SortByTitle:
+		c.Value = last.Title
+	case domain.SortByAuthor:
+		c.Value = last.Author
+	case domain.SortByPrice:
+		c.Value = strconv.FormatInt(last.Price.Amount, 10)
+	case domain.SortByCreatedAt:
+		c.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the last book seen, holding only the ID and the sort
+// field. A cursor is only valid for the sort order it was issued for.
+func decodeCursor(query domain.BookQuery) (*domain.Book, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, false
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != query.Sort || c.Desc != query.Desc {
+		return nil, false
+	}
+
+	book := &domain.Book{ID: c.ID}
+	switch c.Sort {
+	case domain.SortByTitle:
+		book.Title = c.Value
+	case domain.SortByAuthor:
+		book.Author = c.Value
+	case domain.SortByPrice:
+		if book.Price.Amount, err = strconv.ParseInt(c.Value, 10, 64); err != nil {
+			return nil, false
+		}
+	case domain.SortByCreatedAt:
+		if book.CreatedAt, err = time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, false
+		}
+	}
+	return book, true
+}
+
+func normalizeQuery(query *domain.BookQuery) error {
+	v := &validator{}
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit < 0 || query.Limit > MaxPageSize:
+		v.add("limit", "must be between 1 and %d", MaxPageSize)
+	}
+
+	if query.Sort == "" {
+		query.Sort = domain.SortByID
+	}
+	known := false
+	for _, field := range domain.BookSortFields {
+		known = known || field == query.Sort
+	}
+	if !known {
+		v.add("sort", "must be one of %v", domain.BookSortFields)
+	}
+	// Prices in different currencies do not compare, so neither do their
+	// minor units.
+	if query.Sort == domain.SortByPrice && query.Currency == "" {
+		v.add("currency", "is required when sorting by price")
+	}
+
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		v.add("max_price", "must not be less than min_price")
+	}
+
+	if query.Cursor != "" && known {
+		after, ok := decodeCursor(*query)
+		if !ok {
+			v.add("cursor", "is invalid for this sort order")
+		}
+		query.After = after
+	}
+	return v.err()
+}
@@ -0,0 +1,133 @@
+package application
+
+import (
+	"book-apis/domain"
+	"book-apis/logging"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyService makes write requests sent with an Idempotency-Key
+// safe to retry: the first one runs and its response is kept for TTL, and
+// retries are answered with that response instead of running again.
+// While the first one runs its key is held for Lease, after which a
+// request that never finished no longer blocks retries.
+type IdempotencyService struct {
+	repo     domain.IdempotencyRepository
+	tx       domain.Transactor
+	TTL      time.Duration
+	Lease    time.Duration
+	Interval time.Duration
+	now      func() time.Time
+}
+
+func NewIdempotencyService(repo domain.IdempotencyRepository, tx domain.Transactor, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, tx: tx, TTL: ttl, Lease: time.Minute, Interval: time.Hour, now: time.Now}
+}
+
+// Do runs fn unless owner has already made the request identified by key
+// and fingerprint, in which case it returns the saved response and true.
+// The key is claimed in a transaction of its own, so a retry racing the
+// first request is told it is still being processed, and fn runs outside
+// it with its writes committed, and retried, as they would be without a
+// key. Server errors are not saved, leaving the request free to be
+// retried; client errors are saved when they can be.
+func (s *IdempotencyService) Do(ctx context.Context, owner, key, fingerprint string, fn func(ctx context.Context) domain.IdempotentResponse) (domain.IdempotentResponse, bool, error) {
+	record := domain.IdempotencyRecord{Owner: owner, Key: key, Fingerprint: fingerprint, ExpiresAt: s.now().Add(s.Lease)}
+
+	var saved *domain.IdempotencyRecord
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) (err error) {
+		saved, err = s.claim(ctx, &record)
+		return err
+	})
+	if err != nil {
+		return domain.IdempotentResponse{}, false, err
+	}
+	if saved != nil {
+		return saved.Response, true, nil
+	}
+
+	resp := fn(ctx)
+	if resp.Status >= http.StatusInternalServerError {
+		s.release(ctx, &record)
+		return resp, false, nil
+	}
+	record.Response = resp
+	record.ExpiresAt = s.now().Add(s.TTL)
+	if err := s.repo.SaveIdempotentResponse(ctx, &record); err != nil {
+		// A rejected request wrote nothing, so its key can be freed. A
+		// successful one has written, so its key stays held until the
+		// lease runs out rather than letting a retry write again.
+		if resp.Status >= http.StatusBadRequest {
+			logging.FromContext(ctx).WarnContext(ctx, "save idempotent response", "error", err)
+			s.release(ctx, &record)
+		} else {
+			logging.FromContext(ctx).ErrorContext(ctx, "save idempotent response", "error", err)
+		}
+	}
+	return resp, false, nil
+}
+
+// claim creates record's key, or returns the record already saved under
+// it. An expired key is taken over.
+func (s *IdempotencyService) claim(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	for {
+		created, err := s.repo.CreateIdempotencyKey(ctx, record)
+		if err != nil || created {
+			return nil, err
+		}
+		saved, err := s.repo.GetIdempotencyKey(ctx, record.Owner, record.Key)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			continue
+		case err != nil:
+			return nil, err
+		case !saved.ExpiresAt.After(s.now()):
+			if err := s.repo.DeleteIdempotencyKey(ctx, record.Owner, record.Key); err != nil {
+				return nil, err
+			}
+			continue
+		case saved.Fingerprint != record.Fingerprint:
+			return nil, &domain.ValidationError{Message: "Idempotency-Key has already been used for a different request"}
+		case saved.Response.Status == 0:
+			return nil, &domain.ConflictError{Message: "a request with this Idempotency-Key is still being processed"}
+		}
+		return &saved, nil
+	}
+}
+
+// release drops record's key so the request can be made again.
+func (s *IdempotencyService) release(ctx context.Context, record *domain.IdempotencyRecord) {
+	if err := s.repo.DeleteIdempotencyKey(ctx, record.Owner, record.Key); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "release idempotency key", "error", err)
+	}
+}
+
+// PurgeOnce removes expired keys and returns how many went.
+func (s *IdempotencyService) PurgeOnce(ctx context.Context) (int, error) {
+	return s.repo.PurgeIdempotencyKeys(ctx, s.now())
+}
+
+// Run purges expired keys once straight away and then on every tick until
+// ctx is done.
+func (s *IdempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.PurgeOnce(ctx); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "purge expired idempotency keys", "error", err)
+		} else if n > 0 {
+			logging.FromContext(ctx).InfoContext(ctx, "purged expired idempotency keys", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
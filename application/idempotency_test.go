@@ -0,0 +1,148 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/mocks"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdempotencyService_Do(t *testing.T) {
+	created := domain.IdempotentResponse{Status: 201, Header: map[string]string{"Location": "/books/1"}, Body: []byte(`{"id":1}`)}
+	saved := domain.IdempotencyRecord{Owner: "user-1", Key: "key-1", Fingerprint: "fp-1", Response: created, ExpiresAt: time.Now().Add(time.Hour)}
+	mismatched, expired := saved, saved
+	mismatched.Fingerprint = "fp-2"
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	errDB := errors.New("Some DB error")
+
+	type testCase struct {
+		name      string
+		response  domain.IdempotentResponse
+		mockSetup func(repo *mocks.MockIdempotencyRepository)
+		expected  domain.IdempotentResponse
+		replayed  bool
+		ran       bool
+		errIs     error
+	}
+	tests := []testCase{
+		{
+			name:     "success - first request runs and is saved",
+			response: created,
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.MatchedBy(func(r *domain.IdempotencyRecord) bool {
+					return r.ExpiresAt.Before(time.Now().Add(2 * time.Minute))
+				})).Return(true, nil)
+				repo.On("SaveIdempotentResponse", mock.MatchedBy(func(r *domain.IdempotencyRecord) bool {
+					return r.Owner == "user-1" && r.Key == "key-1" && r.Fingerprint == "fp-1" && r.Response.Status == 201 && r.ExpiresAt.After(time.Now().Add(time.Hour-time.Minute))
+				})).Return(nil)
+			},
+			expected: created,
+			ran:      true,
+		},
+		{
+			name: "success - retry is replayed",
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(false, nil)
+				repo.On("GetIdempotencyKey", "user-1", "key-1").Return(saved, nil)
+			},
+			expected: created,
+			replayed: true,
+		},
+		{
+			name:     "success - expired key is taken over",
+			response: created,
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(false, nil).Once()
+				repo.On("GetIdempotencyKey", "user-1", "key-1").Return(expired, nil)
+				repo.On("DeleteIdempotencyKey", "user-1", "key-1").Return(nil)
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(true, nil).Once()
+				repo.On("SaveIdempotentResponse", mock.Anything).Return(nil)
+			},
+			expected: created,
+			ran:      true,
+		},
+		{
+			name:     "success - server error is not saved",
+			response: domain.IdempotentResponse{Status: 503},
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(true, nil)
+				repo.On("DeleteIdempotencyKey", "user-1", "key-1").Return(nil)
+			},
+			expected: domain.IdempotentResponse{Status: 503},
+			ran:      true,
+		},
+		{
+			name:     "success - client error is answered when it can not be saved",
+			response: domain.IdempotentResponse{Status: 422},
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(true, nil)
+				repo.On("SaveIdempotentResponse", mock.Anything).Return(errDB)
+				repo.On("DeleteIdempotencyKey", "user-1", "key-1").Return(nil)
+			},
+			expected: domain.IdempotentResponse{Status: 422},
+			ran:      true,
+		},
+		{
+			name: "not success - key reused for another request",
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(false, nil)
+				repo.On("GetIdempotencyKey", "user-1", "key-1").Return(mismatched, nil)
+			},
+			errIs: domain.ErrValidation,
+		},
+		{
+			name:     "success - success is answered and its key kept when it can not be saved",
+			response: created,
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(true, nil)
+				repo.On("SaveIdempotentResponse", mock.Anything).Return(errDB)
+			},
+			expected: created,
+			ran:      true,
+		},
+		{
+			name: "not success - key is still being processed",
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(false, nil)
+				repo.On("GetIdempotencyKey", "user-1", "key-1").Return(domain.IdempotencyRecord{Owner: "user-1", Key: "key-1", Fingerprint: "fp-1", ExpiresAt: time.Now().Add(time.Minute)}, nil)
+			},
+			errIs: domain.ErrConflict,
+		},
+		{
+			name: "not success - key can not be claimed",
+			mockSetup: func(repo *mocks.MockIdempotencyRepository) {
+				repo.On("CreateIdempotencyKey", mock.Anything).Return(false, errDB)
+			},
+			errIs: errDB,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := new(mocks.MockIdempotencyRepository)
+			tc.mockSetup(repo)
+			service := application.NewIdempotencyService(repo, new(mocks.MockTransactor), time.Hour)
+
+			ran := false
+			resp, replayed, err := service.Do(context.Background(), "user-1", "key-1", "fp-1", func(ctx context.Context) domain.IdempotentResponse {
+				ran = true
+				return tc.response
+			})
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, resp)
+				assert.Equal(t, tc.replayed, replayed)
+			}
+			assert.Equal(t, tc.ran, ran)
+			repo.AssertExpectations(t)
+		})
+	}
+}
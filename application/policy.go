@@ -0,0 +1,124 @@
+package application
+
+import (
+	"book-apis/domain"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Permission is something a role may be allowed to do. Reading the catalog
+// needs no permission.
+type Permission string
+
+const (
+	PermCreateBook Permission = "books:create"
+	// PermUpdateStock allows updates that change nothing but stock.
+	PermUpdateStock Permission = "books:update_stock"
+	PermUpdateBook  Permission = "books:update"
+	// PermDeleteBook covers moving books to the trash and back.
+	PermDeleteBook Permission = "books:delete"
+	PermManageKeys Permission = "keys:manage"
+	PermReadAudit  Permission = "audit:read"
+)
+
+// Policy maps roles to the permissions they grant.
+type Policy struct {
+	grants map[string][]Permission
+}
+
+func NewPolicy(grants map[string][]Permission) *Policy {
+	return &Policy{grants: grants}
+}
+
+// DefaultPolicy lets clerks adjust stock, inventory managers add and edit
+// books, and admins do anything, including deleting books and managing API
+// keys. Viewers may only read the catalog; the audit log is for inventory
+// managers and admins.
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[string][]Permission{
+		domain.RoleViewer:           nil,
+		domain.RoleClerk:            {PermUpdateStock},
+		domain.RoleInventoryManager: {PermCreateBook, PermUpdateStock, PermUpdateBook, PermReadAudit},
+		domain.RoleAdmin:            {PermCreateBook, PermUpdateStock, PermUpdateBook, PermDeleteBook, PermManageKeys, PermReadAudit},
+	})
+}
+
+// Allows reports whether any of the principal's roles grants perm.
+func (p *Policy) Allows(principal domain.Principal, perm Permission) bool {
+	for _, role := range principal.Roles {
+		if slices.Contains(p.grants[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed checks perm against the principal in ctx. Calls without a
+// principal come from inside the process, such as the command line or a
+// server running with authentication disabled, and are trusted.
+func (p *Policy) allowed(ctx context.Context, perm Permission) bool {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	return !ok || p.Allows(principal, perm)
+}
+
+// Authorize fails with a ForbiddenError unless the caller in ctx has perm.
+func (p *Policy) Authorize(ctx context.Context, perm Permission) error {
+	if p.allowed(ctx, perm) {
+		return nil
+	}
+	return p.denied(perm, "")
+}
+
+// AuthorizeUpdate checks a write changing the fields in patch: callers with
+// PermUpdateBook may change anything, callers with only PermUpdateStock
+// just the stock.
+func (p *Policy) AuthorizeUpdate(ctx context.Context, patch domain.BookPatch) error {
+	if p.allowed(ctx, PermUpdateBook) {
+		return nil
+	}
+	if err := p.Authorize(ctx, PermUpdateStock); err != nil {
+		return err
+	}
+	patch.Stock = nil
+	if !patch.IsEmpty() {
+		return p.denied(PermUpdateBook, strings.Join(patchFields(patch), ", "))
+	}
+	return nil
+}
+
+func patchFields(patch domain.BookPatch) []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"title", patch.Title != nil},
+		{"author", patch.Author != nil},
+		{"genre", patch.Genre != nil},
+		{"price", patch.Price != nil},
+		{"stock", patch.Stock != nil},
+		{"isbn", patch.ISBN != nil},
+	} {
+		if f.set {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// denied explains which roles would have been allowed.
+func (p *Policy) denied(perm Permission, fields string) error {
+	var roles []string
+	for _, role := range domain.Roles {
+		if slices.Contains(p.grants[role], perm) {
+			roles = append(roles, role)
+		}
+	}
+	action := string(perm)
+	if fields != "" {
+		action = "changing " + fields
+	}
+	return &domain.ForbiddenError{Message: fmt.Sprintf("%s requires the %s role", action, strings.Join(roles, " or "))}
+}
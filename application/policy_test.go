@@ -0,0 +1,136 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/mocks"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func as(roles ...string) context.Context {
+	return domain.WithPrincipal(context.Background(), domain.Principal{Subject: "user-1", Roles: roles})
+}
+
+func TestBookService_Authorization(t *testing.T) {
+	stored := domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 3}
+	restock := stored
+	restock.Version, restock.Stock = 0, 25
+	reprice := stored
+	reprice.Version, reprice.Price = 0, domain.NewMoney(1, "USD")
+	stock, price := 25, domain.NewMoney(1, "USD")
+
+	writes := map[string]func(s *application.BookService, ctx context.Context) error{
+		"create": func(s *application.BookService, ctx context.Context) error {
+			book := stored
+			_, err := s.CreateBook(ctx, &book)
+			return err
+		},
+		"update stock": func(s *application.BookService, ctx context.Context) error {
+			book := restock
+			_, err := s.UpdateBook(ctx, &book, 1)
+			return err
+		},
+		"update price": func(s *application.BookService, ctx context.Context) error {
+			book := reprice
+			_, err := s.UpdateBook(ctx, &book, 1)
+			return err
+		},
+		"patch stock": func(s *application.BookService, ctx context.Context) error {
+			_, err := s.EditBook(ctx, 1, 0, func(book domain.Book) (domain.Book, error) {
+				book.Stock = stock
+				return book, nil
+			})
+			return err
+		},
+		"patch price": func(s *application.BookService, ctx context.Context) error {
+			_, err := s.EditBook(ctx, 1, 0, func(book domain.Book) (domain.Book, error) {
+				book.Price = price
+				return book, nil
+			})
+			return err
+		},
+		"delete": func(s *application.BookService, ctx context.Context) error {
+			return s.DeleteBook(ctx, 1, 0)
+		},
+		"restore": func(s *application.BookService, ctx context.Context) error {
+			_, err := s.RestoreBook(ctx, 1)
+			return err
+		},
+	}
+
+	type testCase struct {
+		name    string
+		ctx     context.Context
+		allowed []string
+	}
+	tests := []testCase{
+		{name: "no principal", ctx: context.Background(), allowed: []string{"create", "update stock", "update price", "patch stock", "patch price", "delete", "restore"}},
+		{name: "no roles", ctx: as()},
+		{name: "viewer", ctx: as(domain.RoleViewer)},
+		{name: "clerk", ctx: as(domain.RoleClerk), allowed: []string{"update stock", "patch stock"}},
+		{name: "inventory manager", ctx: as(domain.RoleInventoryManager), allowed: []string{"create", "update stock", "update price", "patch stock", "patch price"}},
+		{name: "admin", ctx: as(domain.RoleAdmin), allowed: []string{"create", "update stock", "update price", "patch stock", "patch price", "delete", "restore"}},
+		{name: "viewer and clerk", ctx: as(domain.RoleViewer, domain.RoleClerk), allowed: []string{"update stock", "patch stock"}},
+		{name: "unknown role", ctx: as("owner")},
+	}
+
+	for _, tc := range tests {
+		for write, do := range writes {
+			t.Run(tc.name+"/"+write, func(t *testing.T) {
+				repo := new(mocks.MockBookRepository)
+				repo.On("GetBook", 1).Return(stored, nil).Maybe()
+				repo.On("CreateBook", mock.Anything).Return(&stored, nil).Maybe()
+				repo.On("UpdateBook", mock.Anything, 1).Return(&stored, nil).Maybe()
+				repo.On("PatchBook", mock.Anything, 1).Return(&stored, nil).Maybe()
+				repo.On("DeleteBook", 1, 0).Return(nil).Maybe()
+				repo.On("RestoreBook", 1).Return(&stored, nil).Maybe()
+				service := application.NewBookService(repo)
+
+				err := do(service, tc.ctx)
+				if slices.Contains(tc.allowed, write) {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, domain.ErrForbidden)
+					repo.AssertNotCalled(t, "CreateBook", mock.Anything)
+					repo.AssertNotCalled(t, "UpdateBook", mock.Anything, mock.Anything)
+					repo.AssertNotCalled(t, "PatchBook", mock.Anything, mock.Anything)
+					repo.AssertNotCalled(t, "DeleteBook", mock.Anything, mock.Anything)
+					repo.AssertNotCalled(t, "RestoreBook", mock.Anything)
+				}
+			})
+		}
+	}
+}
+
+func TestBookService_ClerkUpdatePinsVersion(t *testing.T) {
+	stored := domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 3}
+	repo := new(mocks.MockBookRepository)
+	repo.On("GetBook", 1).Return(stored, nil)
+	repo.On("UpdateBook", mock.MatchedBy(func(b *domain.Book) bool { return b.Version == 3 && b.Stock == 25 }), 1).Return(&stored, nil)
+	service := application.NewBookService(repo)
+
+	book := stored
+	book.Version, book.Stock = 0, 25
+	_, err := service.UpdateBook(as(domain.RoleClerk), &book, 1)
+	assert.NoError(t, err)
+
+	book.Version = 2
+	_, err = service.UpdateBook(as(domain.RoleClerk), &book, 1)
+	assert.ErrorIs(t, err, domain.ErrStale)
+	repo.AssertNumberOfCalls(t, "UpdateBook", 1)
+}
+
+func TestPolicy_DeniedMessage(t *testing.T) {
+	policy := application.DefaultPolicy()
+	price := domain.NewMoney(1, "USD")
+
+	err := policy.AuthorizeUpdate(as(domain.RoleClerk), domain.BookPatch{Price: &price})
+	assert.EqualError(t, err, "changing price requires the inventory_manager or admin role")
+	err = policy.Authorize(as(domain.RoleInventoryManager), application.PermDeleteBook)
+	assert.EqualError(t, err, "books:delete requires the admin role")
+}
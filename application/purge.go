@@ -0,0 +1,76 @@
+package application
+
+import (
+	"book-apis/domain"
+	"book-apis/logging"
+	"context"
+	"time"
+)
+
+// Purger hard-deletes books that have been in the trash for longer than
+// Retention, checking every Interval.
+type Purger struct {
+	repo      domain.BookRepository
+	audit     domain.AuditRepository
+	tx        domain.Transactor
+	Retention time.Duration
+	Interval  time.Duration
+	now       func() time.Time
+}
+
+// NewPurger keeps no audit log.
+func NewPurger(repo domain.BookRepository, retention, interval time.Duration) *Purger {
+	return &Purger{repo: repo, Retention: retention, Interval: interval, now: time.Now}
+}
+
+// NewAuditedPurger records every book it removes in audit, in a transaction
+// from tx shared with the removal.
+func NewAuditedPurger(repo domain.BookRepository, audit domain.AuditRepository, tx domain.Transactor, retention, interval time.Duration) *Purger {
+	return &Purger{repo: repo, audit: audit, tx: tx, Retention: retention, Interval: interval, now: time.Now}
+}
+
+// PurgeOnce removes every book deleted before the retention window and
+// returns how many went.
+func (p *Purger) PurgeOnce(ctx context.Context) (int, error) {
+	var IDs []int
+	purge := func(ctx context.Context) (err error) {
+		if IDs, err = p.repo.PurgeDeleted(ctx, p.now().Add(-p.Retention)); err != nil {
+			return err
+		}
+		for _, ID := range IDs {
+			if err := recordAudit(ctx, p.audit, domain.AuditPurge, ID, map[string]domain.Change{"purged": {Before: false, After: true}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if p.tx == nil {
+		err = purge(ctx)
+	} else {
+		err = p.tx.WithinTx(ctx, purge)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(IDs), nil
+}
+
+// Run purges once straight away and then on every tick until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := p.PurgeOnce(ctx); err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "purge deleted books", "error", err)
+		} else if n > 0 {
+			logging.FromContext(ctx).InfoContext(ctx, "purged deleted books", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
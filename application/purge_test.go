@@ -0,0 +1,80 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/mocks"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurger_PurgeOnce(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	purger := application.NewPurger(repo, 24*time.Hour, time.Hour)
+
+	start := time.Now()
+	cutoff := mock.MatchedBy(func(before time.Time) bool {
+		return !before.Before(start.Add(-24*time.Hour)) && !before.After(time.Now().Add(-24*time.Hour))
+	})
+	repo.On("PurgeDeleted", cutoff).Return([]int{3, 5}, nil).Once()
+	n, err := purger.PurgeOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	repo.On("PurgeDeleted", cutoff).Return(nil, errors.New("Oh no error!")).Once()
+	_, err = purger.PurgeOnce(context.Background())
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestPurger_AuditsPurgedBooks(t *testing.T) {
+	repo, audits := new(mocks.MockBookRepository), new(mocks.MockAuditRepository)
+	purger := application.NewAuditedPurger(repo, audits, new(mocks.MockTransactor), 24*time.Hour, time.Hour)
+
+	repo.On("PurgeDeleted", mock.Anything).Return([]int{3, 5}, nil).Once()
+	for _, ID := range []int{3, 5} {
+		audits.On("RecordAudit", &domain.AuditEntry{
+			Actor:   "system",
+			Action:  domain.AuditPurge,
+			BookID:  ID,
+			Changes: map[string]domain.Change{"purged": {Before: false, After: true}},
+		}).Return(nil).Once()
+	}
+	n, err := purger.PurgeOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	repo.On("PurgeDeleted", mock.Anything).Return([]int{7}, nil).Once()
+	audits.On("RecordAudit", mock.Anything).Return(errors.New("Oh no error!")).Once()
+	_, err = purger.PurgeOnce(context.Background())
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+	audits.AssertExpectations(t)
+}
+
+func TestPurger_RunStopsWithContext(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	purged := make(chan struct{}, 10)
+	repo.On("PurgeDeleted", mock.Anything).Return(nil, nil).Run(func(mock.Arguments) { purged <- struct{}{} })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		application.NewPurger(repo, time.Hour, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	<-purged
+	<-purged
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
@@ -0,0 +1,24 @@
+package application
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan opens the span for a BookService method. The tracer is looked
+// up on every call so it follows whatever provider is installed globally.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return otel.Tracer("book-apis/application").Start(ctx, "BookService."+method)
+}
+
+// endSpan marks span failed if err is set and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
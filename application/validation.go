@@ -0,0 +1,104 @@
+package application
+
+import (
+	"book-apis/domain"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxTitleLength  = 255
+	MaxAuthorLength = 255
+	MaxGenreLength  = 100
+	MaxStock        = 1_000_000
+)
+
+type validator struct {
+	fields []domain.FieldError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.fields = append(v.fields, domain.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) text(field, value string, required bool, max int) {
+	switch {
+	case required && strings.TrimSpace(value) == "":
+		v.add(field, "is required")
+	case utf8.RuneCountInString(value) > max:
+		v.add(field, "must be at most %d characters", max)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &domain.ValidationError{Fields: v.fields}
+}
+
+// ValidateBook checks every field of book and reports all problems at once.
+func ValidateBook(book *domain.Book) error {
+	v := &validator{}
+	v.text("title", book.Title, true, MaxTitleLength)
+	v.text("author", book.Author, true, MaxAuthorLength)
+	v.text("genre", book.Genre, false, MaxGenreLength)
+
+	_, knownCurrency := domain.CurrencyExponent(book.Price.Currency)
+	switch {
+	case book.Price.Currency == "":
+		v.add("price", "is required")
+	case !knownCurrency:
+		v.add("price.currency", "must be a supported ISO 4217 code")
+	case book.Price.IsNegative():
+		v.add("price", "must not be negative")
+	}
+
+	if book.Stock < 0 || book.Stock > MaxStock {
+		v.add("stock", "must be between 0 and %d", MaxStock)
+	}
+
+	if book.ISBN != "" && !ValidISBN(book.ISBN) {
+		v.add("isbn", "must be a valid ISBN-10 or ISBN-13")
+	}
+	return v.err()
+}
+
+// ValidISBN reports whether s is an ISBN-10 or ISBN-13 with a correct check
+// digit. Hyphens and spaces are ignored.
+func ValidISBN(s string) bool {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(s)
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, c := range digits {
+			var d int
+			switch {
+			case c >= '0' && c <= '9':
+				d = int(c - '0')
+			case (c == 'X' || c == 'x') && i == 9:
+				d = 10
+			default:
+				return false
+			}
+			sum += d * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		sum := 0
+		for i, c := range digits {
+			if c < '0' || c > '9' {
+				return false
+			}
+			d := int(c - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	default:
+		return false
+	}
+}
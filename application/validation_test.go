@@ -0,0 +1,103 @@
+package application_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBook(t *testing.T) {
+	valid := func() domain.Book {
+		return domain.Book{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, ISBN: "978-0-306-40615-7"}
+	}
+
+	type testCase struct {
+		name     string
+		modify   func(b *domain.Book)
+		expected []domain.FieldError
+	}
+
+	tests := []testCase{
+		{name: "valid book", modify: func(b *domain.Book) {}},
+		{name: "valid without isbn", modify: func(b *domain.Book) { b.ISBN = "" }},
+		{name: "valid zero price", modify: func(b *domain.Book) { b.Price = domain.NewMoney(0, "JPY") }},
+		{
+			name:     "missing title and author",
+			modify:   func(b *domain.Book) { b.Title = " "; b.Author = "" },
+			expected: []domain.FieldError{{Field: "title", Message: "is required"}, {Field: "author", Message: "is required"}},
+		},
+		{
+			name:     "title too long",
+			modify:   func(b *domain.Book) { b.Title = strings.Repeat("a", 256) },
+			expected: []domain.FieldError{{Field: "title", Message: "must be at most 255 characters"}},
+		},
+		{
+			name:     "genre too long",
+			modify:   func(b *domain.Book) { b.Genre = strings.Repeat("a", 101) },
+			expected: []domain.FieldError{{Field: "genre", Message: "must be at most 100 characters"}},
+		},
+		{
+			name:     "missing price",
+			modify:   func(b *domain.Book) { b.Price = domain.Money{} },
+			expected: []domain.FieldError{{Field: "price", Message: "is required"}},
+		},
+		{
+			name:     "negative price",
+			modify:   func(b *domain.Book) { b.Price = domain.NewMoney(-1, "USD") },
+			expected: []domain.FieldError{{Field: "price", Message: "must not be negative"}},
+		},
+		{
+			name:     "unknown currency",
+			modify:   func(b *domain.Book) { b.Price = domain.NewMoney(100, "XYZ") },
+			expected: []domain.FieldError{{Field: "price.currency", Message: "must be a supported ISO 4217 code"}},
+		},
+		{
+			name:     "negative stock",
+			modify:   func(b *domain.Book) { b.Stock = -1 },
+			expected: []domain.FieldError{{Field: "stock", Message: "must be between 0 and 1000000"}},
+		},
+		{
+			name:     "bad isbn checksum",
+			modify:   func(b *domain.Book) { b.ISBN = "978-0-306-40615-6" },
+			expected: []domain.FieldError{{Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			book := valid()
+			tc.modify(&book)
+			err := application.ValidateBook(&book)
+			if tc.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, domain.ErrValidation)
+			var validationErr *domain.ValidationError
+			if assert.True(t, errors.As(err, &validationErr)) {
+				assert.Equal(t, tc.expected, validationErr.Fields)
+			}
+		})
+	}
+}
+
+func TestValidISBN(t *testing.T) {
+	tests := map[string]bool{
+		"0-306-40615-2":     true,
+		"080442957X":        true,
+		"9780306406157":     true,
+		"978 0 306 40615 7": true,
+		"0-306-40615-3":     false,
+		"9780306406158":     false,
+		"97803064061":       false,
+		"X802442957":        false,
+		"978030640615a":     false,
+	}
+	for isbn, expected := range tests {
+		assert.Equal(t, expected, application.ValidISBN(isbn), isbn)
+	}
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the upper-cased flag name to form the
+// environment variable for a setting, so -db-max-open-conns is read from
+// BOOKS_DB_MAX_OPEN_CONNS.
+const EnvPrefix = "BOOKS_"
+
+type Config struct {
+	Store          string        `yaml:"store"`
+	DSN            string        `yaml:"dsn"`
+	Listen         string        `yaml:"listen"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests get to finish
+	// once the server is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	LogLevel        string        `yaml:"log_level"`
+	DB              DB            `yaml:"db"`
+	Trash           Trash         `yaml:"trash"`
+	Features        Features      `yaml:"features"`
+	Tracing         Tracing       `yaml:"tracing"`
+	Auth            Auth          `yaml:"auth"`
+	RateLimit       RateLimit     `yaml:"rate_limit"`
+	Idempotency     Idempotency   `yaml:"idempotency"`
+}
+
+// DB holds the database/sql pool settings. Zero values keep the
+// database/sql defaults.
+type DB struct {
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+type Trash struct {
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+// Tracing selects where spans go: nowhere, pretty printed to stdout, or to
+// an OTLP/HTTP collector at Endpoint. An empty Endpoint leaves the
+// exporter to the standard OTEL_EXPORTER_OTLP_* variables.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Auth guards the book and key management routes. API keys are always
+// accepted; JWTs only when JWKSFile names a key set to check them against.
+type Auth struct {
+	Enabled  bool   `yaml:"enabled"`
+	JWKSFile string `yaml:"jwks_file"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// RateLimit gives each client, told apart by API key or JWT subject and
+// by address otherwise, one token bucket for reads and one for writes.
+// Address is a bucket per client address that every request draws on
+// before its credentials are checked.
+type RateLimit struct {
+	Enabled bool   `yaml:"enabled"`
+	Address Bucket `yaml:"address"`
+	Read    Bucket `yaml:"read"`
+	Write   Bucket `yaml:"write"`
+}
+
+// Bucket holds up to Burst requests and refills at Rate requests a second.
+type Bucket struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Idempotency sets how long the response to a write sent with an
+// Idempotency-Key is kept for replay.
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type Features struct {
+	Purge  bool `yaml:"purge"`
+	Search bool `yaml:"search"`
+}
+
+var (
+	stores         = []string{"mysql", "postgres", "sqlite", "memory"}
+	logLevels      = []string{"debug", "info", "warn", "error"}
+	traceExporters = []string{"none", "stdout", "otlp"}
+)
+
+func Default() Config {
+	return Config{
+		Store:           "mysql",
+		Listen:          ":8080",
+		RequestTimeout:  10 * time.Second,
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		LogLevel:        "info",
+		DB: DB{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Trash: Trash{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Features: Features{Purge: true, Search: true},
+		Tracing:  Tracing{Exporter: "none", SampleRatio: 1},
+		Auth:     Auth{Enabled: true},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Address: Bucket{Rate: 50, Burst: 100},
+			Read:    Bucket{Rate: 20, Burst: 40},
+			Write:   Bucket{Rate: 5, Burst: 10},
+		},
+		Idempotency: Idempotency{TTL: 24 * time.Hour},
+	}
+}
+
+func (c *Config) bind(fs *flag.FlagSet) {
+	fs.StringVar(&c.Store, "store", c.Store, "book storage backend: "+strings.Join(stores, ", "))
+	fs.StringVar(&c.DSN, "dsn", c.DSN, "data source name for the mysql, postgres or sqlite store")
+	fs.StringVar(&c.Listen, "listen", c.Listen, "address the HTTP server listens on")
+	fs.DurationVar(&c.RequestTimeout, "request-timeout", c.RequestTimeout, "how long a request may run before it is answered with 504; 0 disables the limit")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "how long the server waits to read a whole request; 0 is forever")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", c.WriteTimeout, "how long the server may take to write a response; 0 is forever")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "how long a keep-alive connection may sit idle; 0 falls back to the read timeout")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long in-flight requests get to finish on shutdown")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "minimum log level: "+strings.Join(logLevels, ", "))
+	fs.IntVar(&c.DB.MaxOpenConns, "db-max-open-conns", c.DB.MaxOpenConns, "maximum open database connections; 0 is unlimited")
+	fs.IntVar(&c.DB.MaxIdleConns, "db-max-idle-conns", c.DB.MaxIdleConns, "maximum idle database connections")
+	fs.DurationVar(&c.DB.ConnMaxLifetime, "db-conn-max-lifetime", c.DB.ConnMaxLifetime, "how long a database connection may be reused; 0 is forever")
+	fs.DurationVar(&c.DB.ConnMaxIdleTime, "db-conn-max-idle-time", c.DB.ConnMaxIdleTime, "how long a database connection may sit idle; 0 is forever")
+	fs.DurationVar(&c.Trash.Retention, "trash-retention", c.Trash.Retention, "how long deleted books stay in the trash before they are purged")
+	fs.DurationVar(&c.Trash.PurgeInterval, "purge-interval", c.Trash.PurgeInterval, "how often to purge expired books from the trash")
+	fs.StringVar(&c.Tracing.Exporter, "tracing-exporter", c.Tracing.Exporter, "where to send traces: "+strings.Join(traceExporters, ", "))
+	fs.StringVar(&c.Tracing.Endpoint, "tracing-endpoint", c.Tracing.Endpoint, "OTLP/HTTP collector URL for the otlp exporter")
+	fs.Float64Var(&c.Tracing.SampleRatio, "tracing-sample-ratio", c.Tracing.SampleRatio, "fraction of new traces to sample, from 0 to 1")
+	fs.BoolVar(&c.Auth.Enabled, "auth-enabled", c.Auth.Enabled, "require an API key or JWT on every route except health, version and metrics")
+	fs.StringVar(&c.Auth.JWKSFile, "auth-jwks-file", c.Auth.JWKSFile, "JWKS file with the HS256 and RS256 keys JWTs are checked against")
+	fs.StringVar(&c.Auth.Issuer, "auth-jwt-issuer", c.Auth.Issuer, "iss claim JWTs must carry")
+	fs.StringVar(&c.Auth.Audience, "auth-jwt-audience", c.Auth.Audience, "aud claim JWTs must carry")
+	fs.BoolVar(&c.RateLimit.Enabled, "rate-limit-enabled", c.RateLimit.Enabled, "limit how many requests each client may make")
+	fs.Float64Var(&c.RateLimit.Address.Rate, "rate-limit-address-rate", c.RateLimit.Address.Rate, "requests a second each client address is allowed on average")
+	fs.IntVar(&c.RateLimit.Address.Burst, "rate-limit-address-burst", c.RateLimit.Address.Burst, "requests each client address may make at once")
+	fs.Float64Var(&c.RateLimit.Read.Rate, "rate-limit-read-rate", c.RateLimit.Read.Rate, "read requests a second each client is allowed on average")
+	fs.IntVar(&c.RateLimit.Read.Burst, "rate-limit-read-burst", c.RateLimit.Read.Burst, "read requests each client may make at once")
+	fs.Float64Var(&c.RateLimit.Write.Rate, "rate-limit-write-rate", c.RateLimit.Write.Rate, "write requests a second each client is allowed on average")
+	fs.IntVar(&c.RateLimit.Write.Burst, "rate-limit-write-burst", c.RateLimit.Write.Burst, "write requests each client may make at once")
+	fs.DurationVar(&c.Idempotency.TTL, "idempotency-ttl", c.Idempotency.TTL, "how long a write sent with an Idempotency-Key is remembered for replay")
+	fs.BoolVar(&c.Features.Purge, "feature-purge", c.Features.Purge, "run the trash purge job")
+	fs.BoolVar(&c.Features.Search, "feature-search", c.Features.Search, "serve GET /books/search")
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the YAML file named by -config or BOOKS_CONFIG, BOOKS_*
+// environment variables and command line flags. It returns the arguments
+// left after the flags.
+func Load(name string, args []string, getenv func(string) string) (*Config, []string, error) {
+	// Flags are parsed up front to find -config, then replayed on top of
+	// the file and environment.
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	parsed := Default()
+	parsed.bind(fs)
+	path := fs.String("config", getenv(EnvPrefix+"CONFIG"), "path to a YAML config file")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	target := flag.NewFlagSet(name, flag.ContinueOnError)
+	cfg.bind(target)
+	var err error
+	target.VisitAll(func(f *flag.Flag) {
+		key := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value := getenv(key); value != "" && err == nil {
+			if setErr := target.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("%s: %w", key, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			target.Set(f.Name, f.Value.String())
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, fs.Args(), nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if !slices.Contains(stores, c.Store) {
+		invalid("store %q must be one of %s", c.Store, strings.Join(stores, ", "))
+	}
+	if (c.Store == "mysql" || c.Store == "postgres") && c.DSN == "" {
+		invalid("dsn is required for the %s store", c.Store)
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		invalid("listen %q: %v", c.Listen, err)
+	}
+	if c.RequestTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		invalid("server timeouts must not be negative")
+	}
+	if c.WriteTimeout > 0 && c.RequestTimeout >= c.WriteTimeout {
+		invalid("request_timeout must be shorter than write_timeout so timed out requests can still be answered")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout must be positive")
+	}
+	if !slices.Contains(logLevels, c.LogLevel) {
+		invalid("log_level %q must be one of %s", c.LogLevel, strings.Join(logLevels, ", "))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		invalid("db.max_open_conns must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		invalid("db.max_idle_conns must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		invalid("db.max_idle_conns must not exceed db.max_open_conns")
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		invalid("db connection lifetimes must not be negative")
+	}
+	if c.Trash.Retention < 0 {
+		invalid("trash.retention must not be negative")
+	}
+	if c.Features.Purge && c.Trash.PurgeInterval <= 0 {
+		invalid("trash.purge_interval must be positive while the purge job is enabled")
+	}
+	if !slices.Contains(traceExporters, c.Tracing.Exporter) {
+		invalid("tracing.exporter %q must be one of %s", c.Tracing.Exporter, strings.Join(traceExporters, ", "))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio must be between 0 and 1")
+	}
+	if (c.Auth.Issuer != "" || c.Auth.Audience != "") && c.Auth.JWKSFile == "" {
+		invalid("auth.issuer and auth.audience need auth.jwks_file")
+	}
+	if c.RateLimit.Enabled {
+		if b := c.RateLimit.Address; b.Rate <= 0 || b.Burst < 1 {
+			invalid("rate_limit.address needs a positive rate and a burst of at least 1")
+		}
+		if b := c.RateLimit.Read; b.Rate <= 0 || b.Burst < 1 {
+			invalid("rate_limit.read needs a positive rate and a burst of at least 1")
+		}
+		if b := c.RateLimit.Write; b.Rate <= 0 || b.Burst < 1 {
+			invalid("rate_limit.write needs a positive rate and a burst of at least 1")
+		}
+	}
+	if c.Idempotency.TTL <= 0 {
+		invalid("idempotency.ttl must be positive")
+	}
+	return errors.Join(errs...)
+}
+
+// Print writes c as YAML with the password in the DSN masked.
+func (c *Config) Print(w io.Writer) error {
+	out := *c
+	out.DSN = RedactDSN(c.Store, c.DSN)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil {
+		return err
+	}
+	return enc.Close()
+}
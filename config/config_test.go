@@ -0,0 +1,179 @@
+package config_test
+
+import (
+	"book-apis/config"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeFile(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "books.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	file := writeFile(t, `
+store: postgres
+dsn: host=db user=books password=secret
+listen: ":9000"
+db:
+  max_open_conns: 50
+trash:
+  retention: 48h
+features:
+  search: false
+rate_limit:
+  read:
+    rate: 50
+`)
+
+	type testCase struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(c *config.Config)
+		rest     []string
+	}
+	tests := []testCase{
+		{
+			name: "defaults",
+			args: []string{"-store", "memory"},
+			expected: func(c *config.Config) {
+				c.Store = "memory"
+			},
+		},
+		{
+			name: "file",
+			args: []string{"-config", file},
+			expected: func(c *config.Config) {
+				c.Store, c.DSN, c.Listen = "postgres", "host=db user=books password=secret", ":9000"
+				c.DB.MaxOpenConns = 50
+				c.Trash.Retention = 48 * time.Hour
+				c.Features.Search = false
+				c.RateLimit.Read.Rate = 50
+			},
+		},
+		{
+			name: "environment overrides file",
+			env:  map[string]string{"BOOKS_CONFIG": file, "BOOKS_LISTEN": ":9100", "BOOKS_FEATURE_SEARCH": "true", "BOOKS_DB_MAX_IDLE_CONNS": "10", "BOOKS_AUTH_ENABLED": "false"},
+			expected: func(c *config.Config) {
+				c.Store, c.DSN, c.Listen = "postgres", "host=db user=books password=secret", ":9100"
+				c.DB.MaxOpenConns, c.DB.MaxIdleConns = 50, 10
+				c.Auth.Enabled = false
+				c.Trash.Retention = 48 * time.Hour
+				c.RateLimit.Read.Rate = 50
+			},
+		},
+		{
+			name: "flags override environment",
+			args: []string{"-config", file, "-listen", ":9200", "-request-timeout", "1s", "migrate", "up"},
+			env:  map[string]string{"BOOKS_LISTEN": ":9100", "BOOKS_REQUEST_TIMEOUT": "5s"},
+			expected: func(c *config.Config) {
+				c.Store, c.DSN, c.Listen = "postgres", "host=db user=books password=secret", ":9200"
+				c.RequestTimeout = time.Second
+				c.DB.MaxOpenConns = 50
+				c.Trash.Retention = 48 * time.Hour
+				c.Features.Search = false
+				c.RateLimit.Read.Rate = 50
+			},
+			rest: []string{"migrate", "up"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, rest, err := config.Load("books", tc.args, env(tc.env))
+			if err != nil {
+				t.Fatalf("Error loading config: %v", err)
+			}
+			expected := config.Default()
+			tc.expected(&expected)
+			assert.Equal(t, &expected, cfg)
+			assert.ElementsMatch(t, tc.rest, rest)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	type testCase struct {
+		name   string
+		args   []string
+		env    map[string]string
+		errMsg string
+	}
+	tests := []testCase{
+		{name: "unknown store", args: []string{"-store", "oracle"}, errMsg: `store "oracle" must be one of`},
+		{name: "missing dsn", args: []string{"-store", "mysql"}, errMsg: "dsn is required for the mysql store"},
+		{name: "bad listen address", args: []string{"-store", "memory", "-listen", "8080"}, errMsg: `listen "8080"`},
+		{name: "request outlasts write", args: []string{"-store", "memory", "-request-timeout", "1m", "-write-timeout", "30s"}, errMsg: "request_timeout must be shorter than write_timeout"},
+		{name: "no shutdown grace", args: []string{"-store", "memory", "-shutdown-timeout", "0"}, errMsg: "shutdown_timeout must be positive"},
+		{name: "unknown exporter", args: []string{"-store", "memory", "-tracing-exporter", "jaeger"}, errMsg: `tracing.exporter "jaeger"`},
+		{name: "sample ratio above one", args: []string{"-store", "memory", "-tracing-sample-ratio", "2"}, errMsg: "tracing.sample_ratio"},
+		{name: "bad log level", args: []string{"-store", "memory", "-log-level", "loud"}, errMsg: `log_level "loud"`},
+		{name: "idle above open", args: []string{"-store", "memory", "-db-max-open-conns", "2", "-db-max-idle-conns", "3"}, errMsg: "must not exceed"},
+		{name: "purge without interval", args: []string{"-store", "memory", "-purge-interval", "0"}, errMsg: "trash.purge_interval"},
+		{name: "issuer without key set", args: []string{"-store", "memory", "-auth-jwt-issuer", "https://id.example.com"}, errMsg: "auth.issuer and auth.audience need auth.jwks_file"},
+		{name: "empty address bucket", args: []string{"-store", "memory", "-rate-limit-address-rate", "0"}, errMsg: "rate_limit.address needs a positive rate"},
+		{name: "empty write bucket", args: []string{"-store", "memory", "-rate-limit-write-burst", "0"}, errMsg: "rate_limit.write needs a positive rate"},
+		{name: "no idempotency ttl", args: []string{"-store", "memory", "-idempotency-ttl", "0"}, errMsg: "idempotency.ttl must be positive"},
+		{name: "bad environment value", env: map[string]string{"BOOKS_REQUEST_TIMEOUT": "soon"}, errMsg: "BOOKS_REQUEST_TIMEOUT"},
+		{name: "unknown file key", args: []string{"-config", writeFile(t, "port: 8080\n")}, errMsg: "field port not found"},
+		{name: "missing file", args: []string{"-config", "/does/not/exist.yaml"}, errMsg: "no such file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := config.Load("books", tc.args, env(tc.env))
+			assert.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	type testCase struct {
+		name     string
+		store    string
+		dsn      string
+		expected string
+	}
+	tests := []testCase{
+		{name: "mysql", store: "mysql", dsn: "books:secret@tcp(db:3306)/books", expected: "books:xxxxx@tcp(db:3306)/books"},
+		{name: "mysql without password", store: "mysql", dsn: "books@tcp(db:3306)/books", expected: "books@tcp(db:3306)/books"},
+		{name: "postgres keywords", store: "postgres", dsn: "host=db password=secret user=books", expected: "host=db password=xxxxx user=books"},
+		{name: "postgres quoted", store: "postgres", dsn: "password='se cret' host=db", expected: "password=xxxxx host=db"},
+		{name: "postgres url", store: "postgres", dsn: "postgres://books:secret@db:5432/books?sslmode=disable", expected: "postgres://books:xxxxx@db:5432/books?sslmode=disable"},
+		{name: "sqlite", store: "sqlite", dsn: "books.db", expected: "books.db"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, config.RedactDSN(tc.store, tc.dsn))
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	cfg, _, err := config.Load("books", []string{"-store", "mysql", "-dsn", "books:secret@tcp(db:3306)/books"}, env(nil))
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.Print(&out))
+	assert.NotContains(t, out.String(), "secret")
+	assert.Contains(t, out.String(), "dsn: books:xxxxx@tcp(db:3306)/books\n")
+	assert.Contains(t, out.String(), "request_timeout: 10s\n")
+	assert.Equal(t, "books:secret@tcp(db:3306)/books", cfg.DSN)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const redacted = "xxxxx"
+
+var passwordParam = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// RedactDSN masks the password in a DSN for the given store. URL style
+// DSNs are handled for every store.
+func RedactDSN(store, dsn string) string {
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+			}
+			query := u.Query()
+			if query.Has("password") {
+				query.Set("password", redacted)
+				u.RawQuery = query.Encode()
+			}
+			return u.String()
+		}
+	}
+
+	switch store {
+	case "mysql":
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return redacted
+		}
+		if cfg.Passwd != "" {
+			cfg.Passwd = redacted
+		}
+		return cfg.FormatDSN()
+	case "postgres":
+		return passwordParam.ReplaceAllString(dsn, "${1}"+redacted)
+	default:
+		return dsn
+	}
+}
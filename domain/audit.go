@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+var AuditActions = []string{AuditCreate, AuditUpdate, AuditDelete, AuditRestore, AuditPurge}
+
+// Change is the value of one book field before and after a write. Before
+// is nil for fields of a created book.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry records one write to a book. Actor is the principal's subject,
+// or "system" for writes made from inside the process.
+type AuditEntry struct {
+	ID        int               `json:"id"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	BookID    int               `json:"book_id"`
+	Changes   map[string]Change `json:"changes"`
+	RequestID string            `json:"request_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// AuditQuery filters the audit log, which is listed newest first. Zero
+// fields match everything; Before pages back from an entry ID.
+type AuditQuery struct {
+	BookID int
+	Actor  string
+	Action string
+	Since  *time.Time
+	Until  *time.Time
+	Before int
+	Limit  int
+}
+
+type AuditPage struct {
+	Entries []AuditEntry
+	// NextBefore is the Before of the next page, or 0 on the last one.
+	NextBefore int
+}
+
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, entry *AuditEntry) error
+	ListAudit(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
+}
+
+// Transactor runs fn in a transaction that repositories called with the
+// context it is given take part in.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Roles, from least to most trusted. What each may do is decided by the
+// policy in the application layer.
+const (
+	RoleViewer           = "viewer"
+	RoleClerk            = "clerk"
+	RoleInventoryManager = "inventory_manager"
+	RoleAdmin            = "admin"
+)
+
+var Roles = []string{RoleViewer, RoleClerk, RoleInventoryManager, RoleAdmin}
+
+// Principal is the authenticated caller of a request. Method is "api_key"
+// or "jwt" depending on the credential it presented, and Subject is
+// prefixed with it: "api_key:<id>" or "jwt:<sub>".
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Method  string   `json:"method"`
+	Roles   []string `json:"roles"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal authenticated for the request
+// ctx belongs to, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// APIKey is a stored API key. Only a SHA-256 hash of the secret is kept;
+// Prefix is its first few characters so keys can be told apart in
+// listings.
+type APIKey struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"-"`
+	Roles     []string   `json:"roles"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRepository stores API keys. FindAPIKey looks a key up by the hash
+// of its secret and returns revoked keys too; RevokeAPIKey on a key that is
+// already revoked is a no-op.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) (*APIKey, error)
+	FindAPIKey(ctx context.Context, hash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, ID int) error
+}
+
+// TokenVerifier checks a bearer token's signature and claims and returns
+// the principal it was issued to.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (Principal, error)
+}
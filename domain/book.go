@@ -1,22 +1,49 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Book struct {
 	ID        int       `json:"id"`
 	Title     string    `json:"title"`
 	Author    string    `json:"author"`
 	Genre     string    `json:"genre"`
-	Price     string    `json:"price"`
+	Price     Money     `json:"price"`
 	Stock     int       `json:"stock"`
+	ISBN      string    `json:"isbn"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+	// DeletedAt is set while the book is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+// SearchResult is a book matching a full-text search. Snippet is the
+// HTML-escaped title, author and genre with matched terms in <mark> tags.
+type SearchResult struct {
+	Book    Book    `json:"book"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// BookRepository writes are compare-and-swap on Book.Version or
+// BookPatch.Version when it is non-zero: the write fails with a StaleError
+// unless the stored version matches, and every write bumps the version.
+//
+// DeleteBook only moves a book to the trash. Books in the trash are left
+// out of every read and write except GetAll with BookQuery.Deleted set,
+// RestoreBook and PurgeDeleted, which removes them for good.
 type BookRepository interface {
-	GetAll() ([]Book, error)
-	GetBook(ID int) (Book, error)
-	CreateBook(book *Book) (*Book, error)
-	UpdateBook(book *Book, ID int) (*Book, error)
-	DeleteBook(ID int) error
+	GetAll(ctx context.Context, query BookQuery) ([]Book, error)
+	GetBook(ctx context.Context, ID int) (Book, error)
+	CreateBook(ctx context.Context, book *Book) (*Book, error)
+	UpdateBook(ctx context.Context, book *Book, ID int) (*Book, error)
+	PatchBook(ctx context.Context, patch BookPatch, ID int) (*Book, error)
+	DeleteBook(ctx context.Context, ID int, version int) error
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	RestoreBook(ctx context.Context, ID int) (*Book, error)
+	// PurgeDeleted returns the IDs of the books it removed.
+	PurgeDeleted(ctx context.Context, before time.Time) ([]int, error)
 }
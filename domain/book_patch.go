@@ -0,0 +1,64 @@
+package domain
+
+// BookPatch lists the fields a partial update changes. Nil fields are left
+// as they are. Version is the expected current version, or 0 for any.
+type BookPatch struct {
+	Version int
+
+	Title  *string
+	Author *string
+	Genre  *string
+	Price  *Money
+	Stock  *int
+	ISBN   *string
+}
+
+// DiffBook returns the patch that turns before into after.
+func DiffBook(before, after *Book) BookPatch {
+	var patch BookPatch
+	if after.Title != before.Title {
+		patch.Title = &after.Title
+	}
+	if after.Author != before.Author {
+		patch.Author = &after.Author
+	}
+	if after.Genre != before.Genre {
+		patch.Genre = &after.Genre
+	}
+	if after.Price != before.Price {
+		patch.Price = &after.Price
+	}
+	if after.Stock != before.Stock {
+		patch.Stock = &after.Stock
+	}
+	if after.ISBN != before.ISBN {
+		patch.ISBN = &after.ISBN
+	}
+	return patch
+}
+
+// IsEmpty reports whether p changes no fields.
+func (p BookPatch) IsEmpty() bool {
+	return p == BookPatch{Version: p.Version}
+}
+
+func (p BookPatch) Apply(book *Book) {
+	if p.Title != nil {
+		book.Title = *p.Title
+	}
+	if p.Author != nil {
+		book.Author = *p.Author
+	}
+	if p.Genre != nil {
+		book.Genre = *p.Genre
+	}
+	if p.Price != nil {
+		book.Price = *p.Price
+	}
+	if p.Stock != nil {
+		book.Stock = *p.Stock
+	}
+	if p.ISBN != nil {
+		book.ISBN = *p.ISBN
+	}
+}
@@ -0,0 +1,36 @@
+package domain
+
+const (
+	SortByID        = "id"
+	SortByTitle     = "title"
+	SortByAuthor    = "author"
+	SortByPrice     = "price"
+	SortByCreatedAt = "created_at"
+)
+
+var BookSortFields = []string{SortByID, SortByTitle, SortByAuthor, SortByPrice, SortByCreatedAt}
+
+// BookQuery selects a page of books. Zero-valued filters are ignored.
+// Cursor is the opaque BookPage.NextCursor of the previous page; the service
+// decodes it into After, the last book seen, and repositories return only
+// rows strictly after it in sort order.
+type BookQuery struct {
+	Limit    int
+	Sort     string
+	Desc     bool
+	Cursor   string
+	After    *Book
+	Genre    string
+	Author   string
+	Currency string
+	MinPrice *int64
+	MaxPrice *int64
+	InStock  bool
+	// Deleted lists the trash instead of live books.
+	Deleted bool
+}
+
+type BookPage struct {
+	Books      []Book
+	NextCursor string
+}
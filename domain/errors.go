@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("service unavailable")
+	ErrStale       = errors.New("precondition failed")
+	ErrTimeout     = errors.New("timed out")
+	// ErrUnauthorized and ErrForbidden separate unknown callers from
+	// known ones lacking permission.
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+type NotFoundError struct {
+	Resource string
+	ID       int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Resource, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+type ConflictError struct {
+	Message string
+	Err     error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// StaleError reports a conditional write whose expected version no longer
+// matches the stored one.
+type StaleError struct {
+	Resource string
+	ID       int
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%s %d has been modified", e.Resource, e.ID)
+}
+
+func (e *StaleError) Is(target error) bool {
+	return target == ErrStale
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if e.Message != "" || len(e.Fields) == 0 {
+		return e.Message
+	}
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return ErrValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	if e.Err == nil {
+		return ErrUnavailable.Error()
+	}
+	return ErrUnavailable.Error() + ": " + e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// TimeoutError reports an operation abandoned because its deadline passed.
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return ErrTimeout.Error()
+	}
+	return ErrTimeout.Error() + ": " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// UnauthorizedError reports a request without valid credentials.
+type UnauthorizedError struct {
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	if e.Message == "" {
+		return ErrUnauthorized.Error()
+	}
+	return e.Message
+}
+
+func (e *UnauthorizedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// ForbiddenError reports a principal that may not perform an action.
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Message == "" {
+		return ErrForbidden.Error()
+	}
+	return e.Message
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotentResponse is the answer saved for a write request so that a
+// retry with the same Idempotency-Key can be given it again.
+type IdempotentResponse struct {
+	Status int
+	Header map[string]string
+	Body   []byte
+}
+
+// IdempotencyRecord is a write request made with an Idempotency-Key. Keys
+// are scoped to the Owner that sent them, and Fingerprint identifies the
+// request so a key reused for a different one can be told apart from a
+// retry.
+type IdempotencyRecord struct {
+	Owner       string
+	Key         string
+	Fingerprint string
+	Response    IdempotentResponse
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+type IdempotencyRepository interface {
+	// CreateIdempotencyKey reports false when the owner has already used
+	// the key. Inside a transaction it waits for one that is creating the
+	// same key to finish first.
+	CreateIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (bool, error)
+	GetIdempotencyKey(ctx context.Context, owner, key string) (IdempotencyRecord, error)
+	// SaveIdempotentResponse stores record's Response and ExpiresAt under
+	// its key.
+	SaveIdempotentResponse(ctx context.Context, record *IdempotencyRecord) error
+	DeleteIdempotencyKey(ctx context.Context, owner, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+}
@@ -0,0 +1,248 @@
+package domain
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed for prices sent as a bare decimal string.
+const DefaultCurrency = "USD"
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// currencyExponents maps supported ISO 4217 codes to their number of minor
+// unit digits.
+var currencyExponents = map[string]int{
+	"AUD": 2, "BDT": 2, "BHD": 3, "CAD": 2, "CHF": 2, "CNY": 2, "EUR": 2,
+	"GBP": 2, "INR": 2, "JPY": 0, "KRW": 0, "KWD": 3, "NZD": 2, "SEK": 2,
+	"SGD": 2, "USD": 2,
+}
+
+func CurrencyExponent(currency string) (int, bool) {
+	exp, ok := currencyExponents[currency]
+	return exp, ok
+}
+
+// Money is an exact amount in the minor units of an ISO 4217 currency,
+// e.g. {1250, "USD"} is 12.50 USD.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "12.50" in currency. It fails
+// rather than rounds when amount has more digits than the currency allows.
+func ParseMoney(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	exp, ok := CurrencyExponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(frac) || (strings.Contains(s, ".") && frac == "") {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", amount, exp, currency)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount with the currency's number of decimal places.
+func (m Money) Decimal() string {
+	exp, ok := CurrencyExponent(m.Currency)
+	if !ok {
+		exp = 2
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	sum := m.Amount + other.Amount
+	if (sum > m.Amount) != (other.Amount > 0) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, other)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	diff := m.Amount - other.Amount
+	if (diff < m.Amount) != (other.Amount > 0) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, other)
+	}
+	return Money{Amount: diff, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Amount * n
+	if (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) || (n != 0 && product/n != m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, n)
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount": "12.50", "currency": "USD"}; the
+// amount is a string so clients never round-trip it through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts the object form as well as a bare decimal string or
+// number, which is read in DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	var v moneyJSON
+	switch data[0] {
+	case '{':
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		if v.Currency == "" {
+			v.Currency = DefaultCurrency
+		}
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v = moneyJSON{Amount: json.Number(s), Currency: DefaultCurrency}
+	default:
+		v = moneyJSON{Amount: json.Number(data), Currency: DefaultCurrency}
+	}
+
+	parsed, err := ParseMoney(v.Amount.String(), v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount in minor units. The currency lives in its own
+// column and is written separately.
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+// Scan reads an amount in minor units and leaves Currency untouched, so the
+// currency column must be scanned into m.Currency as well.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		m.Amount = v
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case nil:
+		m.Amount = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(s string) error {
+	amount, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money: %w", s, err)
+	}
+	m.Amount = amount
+	return nil
+}
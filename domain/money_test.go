@@ -0,0 +1,162 @@
+package domain_test
+
+import (
+	"book-apis/domain"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	type testCase struct {
+		amount      string
+		currency    string
+		expected    domain.Money
+		shouldError bool
+	}
+
+	tests := []testCase{
+		{amount: "12.50", currency: "USD", expected: domain.NewMoney(1250, "USD")},
+		{amount: "12.5", currency: "usd", expected: domain.NewMoney(1250, "USD")},
+		{amount: "100", currency: "USD", expected: domain.NewMoney(10000, "USD")},
+		{amount: "0.05", currency: "EUR", expected: domain.NewMoney(5, "EUR")},
+		{amount: "-3.10", currency: "GBP", expected: domain.NewMoney(-310, "GBP")},
+		{amount: "1500", currency: "JPY", expected: domain.NewMoney(1500, "JPY")},
+		{amount: "1.234", currency: "KWD", expected: domain.NewMoney(1234, "KWD")},
+		{amount: "1.234", currency: "USD", shouldError: true},
+		{amount: "1.5", currency: "JPY", shouldError: true},
+		{amount: "abc", currency: "USD", shouldError: true},
+		{amount: "1.", currency: "USD", shouldError: true},
+		{amount: ".5", currency: "USD", shouldError: true},
+		{amount: "1", currency: "XYZ", shouldError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.amount+" "+tc.currency, func(t *testing.T) {
+			m, err := domain.ParseMoney(tc.amount, tc.currency)
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, m)
+			}
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "12.50 USD", domain.NewMoney(1250, "USD").String())
+	assert.Equal(t, "0.05 USD", domain.NewMoney(5, "USD").String())
+	assert.Equal(t, "-0.05 USD", domain.NewMoney(-5, "USD").String())
+	assert.Equal(t, "1500 JPY", domain.NewMoney(1500, "JPY").String())
+	assert.Equal(t, "1.234 KWD", domain.NewMoney(1234, "KWD").String())
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	a := domain.NewMoney(1999, "USD")
+	b := domain.NewMoney(1, "USD")
+
+	sum, err := a.Add(b)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(2000, "USD"), sum)
+
+	diff, err := a.Sub(b)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(1998, "USD"), diff)
+
+	// 0.10 * 3 is exactly 0.30, unlike float64.
+	product, err := domain.NewMoney(10, "USD").Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(30, "USD"), product)
+
+	cmp, err := a.Cmp(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cmp)
+
+	_, err = a.Add(domain.NewMoney(1, "EUR"))
+	assert.ErrorIs(t, err, domain.ErrCurrencyMismatch)
+	_, err = a.Cmp(domain.NewMoney(1, "EUR"))
+	assert.ErrorIs(t, err, domain.ErrCurrencyMismatch)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(domain.NewMoney(1250, "USD"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": "12.50", "currency": "USD"}`, string(data))
+
+	type testCase struct {
+		input       string
+		expected    domain.Money
+		shouldError bool
+	}
+	tests := []testCase{
+		{input: `{"amount": "12.50", "currency": "EUR"}`, expected: domain.NewMoney(1250, "EUR")},
+		{input: `{"amount": 12.5, "currency": "EUR"}`, expected: domain.NewMoney(1250, "EUR")},
+		{input: `{"amount": "12.50"}`, expected: domain.NewMoney(1250, "USD")},
+		{input: `"100"`, expected: domain.NewMoney(10000, "USD")},
+		{input: `99.99`, expected: domain.NewMoney(9999, "USD")},
+		{input: `null`, expected: domain.Money{}},
+		{input: `{"amount": "1.001", "currency": "USD"}`, shouldError: true},
+		{input: `"abc"`, shouldError: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			var m domain.Money
+			err := json.Unmarshal([]byte(tc.input), &m)
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, m)
+			}
+		})
+	}
+}
+
+func TestMoney_SQL(t *testing.T) {
+	v, err := domain.NewMoney(1250, "USD").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1250), v)
+
+	m := domain.Money{Currency: "EUR"}
+	assert.NoError(t, m.Scan(int64(42)))
+	assert.Equal(t, domain.NewMoney(42, "EUR"), m)
+	assert.NoError(t, m.Scan([]byte("1250")))
+	assert.Equal(t, int64(1250), m.Amount)
+	assert.Error(t, m.Scan("12.50"))
+	assert.Error(t, m.Scan(1.5))
+}
+
+func TestMoney_Overflow(t *testing.T) {
+	max := domain.NewMoney(math.MaxInt64, "USD")
+	min := domain.NewMoney(math.MinInt64, "USD")
+	one := domain.NewMoney(1, "USD")
+
+	_, err := max.Add(one)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+	_, err = min.Sub(one)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+	_, err = one.Sub(min)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+	_, err = domain.NewMoney(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+	_, err = min.Mul(-1)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+	_, err = domain.NewMoney(-1, "USD").Mul(math.MinInt64)
+	assert.ErrorIs(t, err, domain.ErrOverflow)
+
+	sum, err := max.Add(domain.NewMoney(-1, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(math.MaxInt64-1, "USD"), sum)
+	diff, err := min.Sub(domain.NewMoney(-1, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(math.MinInt64+1, "USD"), diff)
+	product, err := domain.NewMoney(-4, "USD").Mul(-3)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.NewMoney(12, "USD"), product)
+	product, err = max.Mul(0)
+	assert.NoError(t, err)
+	assert.True(t, product.IsZero())
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimit is a token bucket that holds up to Burst requests and refills
+// at Rate requests a second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitResult is the state of a bucket after a request was taken from
+// it. RetryAfter is how long until the next request would be allowed and
+// Reset how long until the bucket is full again.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// RateLimitStore keeps one bucket per key.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
@@ -0,0 +1,115 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, roles, created_at, revoked_at`
+
+// APIKeyRepositoryDB stores API keys in the api_keys table of a MySQL,
+// PostgreSQL or SQLite database.
+type APIKeyRepositoryDB struct {
+	DB      *sql.DB
+	dialect sqlDialect
+}
+
+func NewAPIKeyRepositoryDB(db *sql.DB) *APIKeyRepositoryDB {
+	return &APIKeyRepositoryDB{DB: db, dialect: mysqlDialect}
+}
+
+func NewAPIKeyRepositoryPostgres(db *sql.DB) *APIKeyRepositoryDB {
+	return &APIKeyRepositoryDB{DB: db, dialect: postgresDialect}
+}
+
+func NewAPIKeyRepositorySQLite(db *sql.DB) *APIKeyRepositoryDB {
+	return &APIKeyRepositoryDB{DB: db, dialect: sqliteDialect}
+}
+
+func scanAPIKey(row interface{ Scan(...any) error }) (domain.APIKey, error) {
+	var (
+		key   domain.APIKey
+		roles string
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &roles, &key.CreatedAt, &key.RevokedAt)
+	if roles != "" {
+		key.Roles = strings.Split(roles, ",")
+	}
+	return key, err
+}
+
+func (r *APIKeyRepositoryDB) CreateAPIKey(ctx context.Context, newKey *domain.APIKey) (*domain.APIKey, error) {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `INSERT INTO api_keys (name, prefix, key_hash, roles) VALUES (` +
+		b.arg(newKey.Name) + `, ` + b.arg(newKey.Prefix) + `, ` + b.arg(newKey.Hash) + `, ` + b.arg(strings.Join(newKey.Roles, ",")) + `)`
+
+	var ID int64
+	if r.dialect.returning {
+		if err := r.DB.QueryRowContext(ctx, query+` RETURNING id`, b.args...).Scan(&ID); err != nil {
+			return nil, dbError(err, "api key", 0)
+		}
+	} else {
+		result, err := r.DB.ExecContext(ctx, query, b.args...)
+		if err != nil {
+			return nil, dbError(err, "api key", 0)
+		}
+		if ID, err = result.LastInsertId(); err != nil {
+			return nil, dbError(err, "api key", 0)
+		}
+	}
+
+	key, err := scanAPIKey(r.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = `+r.dialect.placeholder(1), ID))
+	if err != nil {
+		return nil, dbError(err, "api key", int(ID))
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepositoryDB) FindAPIKey(ctx context.Context, hash string) (domain.APIKey, error) {
+	key, err := scanAPIKey(r.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = `+r.dialect.placeholder(1), hash))
+	if err != nil {
+		return domain.APIKey{}, dbError(err, "api key", 0)
+	}
+	return key, nil
+}
+
+func (r *APIKeyRepositoryDB) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, dbError(err, "api key", 0)
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, dbError(err, "api key", 0)
+		}
+		keys = append(keys, key)
+	}
+	return keys, dbError(rows.Err(), "api key", 0)
+}
+
+func (r *APIKeyRepositoryDB) RevokeAPIKey(ctx context.Context, ID int) error {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `UPDATE api_keys SET revoked_at = ` + b.arg(r.dialect.timeArg(time.Now())) + ` WHERE id = ` + b.arg(ID) + ` AND revoked_at IS NULL`
+	result, err := r.DB.ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return dbError(err, "api key", ID)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err, "api key", ID)
+	}
+	if n == 0 {
+		// Either there is no such key or it was already revoked.
+		var exists int
+		err := r.DB.QueryRowContext(ctx, `SELECT 1 FROM api_keys WHERE id = `+r.dialect.placeholder(1), ID).Scan(&exists)
+		return dbError(err, "api key", ID)
+	}
+	return nil
+}
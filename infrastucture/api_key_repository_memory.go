@@ -0,0 +1,76 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type APIKeyRepositoryMemory struct {
+	mu     sync.RWMutex
+	keys   []domain.APIKey
+	nextID int
+	now    func() time.Time
+}
+
+func NewAPIKeyRepositoryMemory() *APIKeyRepositoryMemory {
+	return &APIKeyRepositoryMemory{
+		nextID: 1,
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (r *APIKeyRepositoryMemory) CreateAPIKey(ctx context.Context, newKey *domain.APIKey) (*domain.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.keys {
+		if key.Hash == newKey.Hash {
+			return nil, &domain.ConflictError{Message: "api key already exists"}
+		}
+	}
+	key := *newKey
+	key.ID = r.nextID
+	key.Roles = slices.Clone(newKey.Roles)
+	key.CreatedAt = r.now()
+	key.RevokedAt = nil
+	r.nextID++
+	r.keys = append(r.keys, key)
+	return &key, nil
+}
+
+func (r *APIKeyRepositoryMemory) FindAPIKey(ctx context.Context, hash string) (domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return domain.APIKey{}, &domain.NotFoundError{Resource: "api key"}
+}
+
+func (r *APIKeyRepositoryMemory) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.keys), nil
+}
+
+func (r *APIKeyRepositoryMemory) RevokeAPIKey(ctx context.Context, ID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].ID == ID {
+			if r.keys[i].RevokedAt == nil {
+				now := r.now()
+				r.keys[i].RevokedAt = &now
+			}
+			return nil
+		}
+	}
+	return &domain.NotFoundError{Resource: "api key", ID: ID}
+}
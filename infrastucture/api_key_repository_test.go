@@ -0,0 +1,89 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAPIKeyRepositoryContract(t *testing.T, repo domain.APIKeyRepository) {
+	ctx := context.Background()
+
+	keys, err := repo.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	created, err := repo.CreateAPIKey(ctx, &domain.APIKey{Name: "ci", Prefix: "bk_abcdefgh", Hash: "hash-1", Roles: []string{"clerk", "viewer"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created.ID)
+	assert.Equal(t, []string{"clerk", "viewer"}, created.Roles)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Nil(t, created.RevokedAt)
+
+	_, err = repo.CreateAPIKey(ctx, &domain.APIKey{Name: "copy", Prefix: "bk_abcdefgh", Hash: "hash-1"})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	second, err := repo.CreateAPIKey(ctx, &domain.APIKey{Name: "ops", Prefix: "bk_ijklmnop", Hash: "hash-2"})
+	assert.NoError(t, err)
+	assert.Empty(t, second.Roles)
+
+	found, err := repo.FindAPIKey(ctx, "hash-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", found.Name)
+	_, err = repo.FindAPIKey(ctx, "hash-3")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	assert.NoError(t, repo.RevokeAPIKey(ctx, created.ID))
+	assert.NoError(t, repo.RevokeAPIKey(ctx, created.ID))
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, 99), domain.ErrNotFound)
+
+	found, err = repo.FindAPIKey(ctx, "hash-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, found.RevokedAt)
+
+	keys, err = repo.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "ci", keys[0].Name)
+		assert.NotNil(t, keys[0].RevokedAt)
+		assert.Equal(t, "ops", keys[1].Name)
+		assert.Nil(t, keys[1].RevokedAt)
+	}
+}
+
+func TestAPIKeyRepositoryMemory(t *testing.T) {
+	testAPIKeyRepositoryContract(t, infrastucture.NewAPIKeyRepositoryMemory())
+}
+
+func TestAPIKeyRepositorySQLite(t *testing.T) {
+	testAPIKeyRepositoryContract(t, infrastucture.NewAPIKeyRepositorySQLite(newSQLiteDB(t)))
+}
+
+func TestAPIKeyRepositoryPostgres_CreateAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys (name, prefix, key_hash, roles) VALUES ($1, $2, $3, $4) RETURNING id")).
+		WithArgs("ci", "bk_abcdefgh", "hash-1", "admin,clerk").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM api_keys WHERE id = $1")).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "key_hash", "roles", "created_at", "revoked_at"}).
+			AddRow(4, "ci", "bk_abcdefgh", "hash-1", "admin,clerk", now, nil))
+
+	repo := infrastucture.NewAPIKeyRepositoryPostgres(db)
+	key, err := repo.CreateAPIKey(context.Background(), &domain.APIKey{Name: "ci", Prefix: "bk_abcdefgh", Hash: "hash-1", Roles: []string{"admin", "clerk"}})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.APIKey{ID: 4, Name: "ci", Prefix: "bk_abcdefgh", Hash: "hash-1", Roles: []string{"admin", "clerk"}, CreatedAt: now}, key)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
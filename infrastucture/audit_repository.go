@@ -0,0 +1,96 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+const auditColumns = `id, actor, action, book_id, changes, request_id, created_at`
+
+// AuditRepositoryDB keeps the audit log in the audit_log table. Entries
+// recorded inside a SQLTransactor transaction commit or roll back with it.
+type AuditRepositoryDB struct {
+	DB      *sql.DB
+	dialect sqlDialect
+}
+
+func NewAuditRepositoryDB(db *sql.DB) *AuditRepositoryDB {
+	return &AuditRepositoryDB{DB: db, dialect: mysqlDialect}
+}
+
+func NewAuditRepositoryPostgres(db *sql.DB) *AuditRepositoryDB {
+	return &AuditRepositoryDB{DB: db, dialect: postgresDialect}
+}
+
+func NewAuditRepositorySQLite(db *sql.DB) *AuditRepositoryDB {
+	return &AuditRepositoryDB{DB: db, dialect: sqliteDialect}
+}
+
+func (r *AuditRepositoryDB) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	b := &queryBuilder{dialect: r.dialect}
+	query := `INSERT INTO audit_log (actor, action, book_id, changes, request_id) VALUES (` +
+		b.arg(entry.Actor) + `, ` + b.arg(entry.Action) + `, ` + b.arg(entry.BookID) + `, ` + b.arg(string(changes)) + `, ` + b.arg(entry.RequestID) + `)`
+	_, err = conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	return dbError(err, "audit entry", 0)
+}
+
+func (r *AuditRepositoryDB) ListAudit(ctx context.Context, q domain.AuditQuery) ([]domain.AuditEntry, error) {
+	b := &queryBuilder{dialect: r.dialect}
+	var where []string
+	if q.BookID != 0 {
+		where = append(where, `book_id = `+b.arg(q.BookID))
+	}
+	if q.Actor != "" {
+		where = append(where, `actor = `+b.arg(q.Actor))
+	}
+	if q.Action != "" {
+		where = append(where, `action = `+b.arg(q.Action))
+	}
+	if q.Since != nil {
+		where = append(where, `created_at >= `+b.arg(r.dialect.timeArg(*q.Since)))
+	}
+	if q.Until != nil {
+		where = append(where, `created_at < `+b.arg(r.dialect.timeArg(*q.Until)))
+	}
+	if q.Before != 0 {
+		where = append(where, `id < `+b.arg(q.Before))
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY id DESC`
+	if q.Limit > 0 {
+		query += ` LIMIT ` + b.arg(q.Limit)
+	}
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, dbError(err, "audit entry", 0)
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		var (
+			entry   domain.AuditEntry
+			changes string
+		)
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.BookID, &changes, &entry.RequestID, &entry.CreatedAt); err != nil {
+			return nil, dbError(err, "audit entry", 0)
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, dbError(rows.Err(), "audit entry", 0)
+}
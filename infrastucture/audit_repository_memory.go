@@ -0,0 +1,55 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+type AuditRepositoryMemory struct {
+	mu      sync.RWMutex
+	entries []domain.AuditEntry
+	now     func() time.Time
+}
+
+func NewAuditRepositoryMemory() *AuditRepositoryMemory {
+	return &AuditRepositoryMemory{now: func() time.Time { return time.Now().UTC() }}
+}
+
+func (r *AuditRepositoryMemory) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *entry
+	stored.ID = len(r.entries) + 1
+	stored.Changes = maps.Clone(entry.Changes)
+	stored.CreatedAt = r.now()
+	r.entries = append(r.entries, stored)
+	return nil
+}
+
+func (r *AuditRepositoryMemory) ListAudit(ctx context.Context, q domain.AuditQuery) ([]domain.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []domain.AuditEntry
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		switch {
+		case q.BookID != 0 && entry.BookID != q.BookID,
+			q.Actor != "" && entry.Actor != q.Actor,
+			q.Action != "" && entry.Action != q.Action,
+			q.Since != nil && entry.CreatedAt.Before(*q.Since),
+			q.Until != nil && !entry.CreatedAt.Before(*q.Until),
+			q.Before != 0 && entry.ID >= q.Before:
+			continue
+		}
+		entries = append(entries, entry)
+		if q.Limit > 0 && len(entries) == q.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,119 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuditRepositoryContract(t *testing.T, repo domain.AuditRepository) {
+	ctx := context.Background()
+	record := func(actor, action string, bookID int) {
+		err := repo.RecordAudit(ctx, &domain.AuditEntry{
+			Actor:     actor,
+			Action:    action,
+			BookID:    bookID,
+			Changes:   map[string]domain.Change{"stock": {Before: 1.0, After: 2.0}},
+			RequestID: "req-1",
+		})
+		assert.NoError(t, err)
+	}
+	record("api_key:1", domain.AuditCreate, 1)
+	record("api_key:2", domain.AuditUpdate, 1)
+	record("api_key:1", domain.AuditCreate, 2)
+	record("api_key:1", domain.AuditDelete, 1)
+
+	ids := func(q domain.AuditQuery) []int {
+		entries, err := repo.ListAudit(ctx, q)
+		assert.NoError(t, err)
+		var ids []int
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []int{4, 3, 2, 1}, ids(domain.AuditQuery{}))
+	assert.Equal(t, []int{4, 2, 1}, ids(domain.AuditQuery{BookID: 1}))
+	assert.Equal(t, []int{4, 3, 1}, ids(domain.AuditQuery{Actor: "api_key:1"}))
+	assert.Equal(t, []int{3, 1}, ids(domain.AuditQuery{Action: domain.AuditCreate}))
+	assert.Equal(t, []int{2, 1}, ids(domain.AuditQuery{Before: 3}))
+	assert.Equal(t, []int{4, 2}, ids(domain.AuditQuery{BookID: 1, Limit: 2}))
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	assert.Len(t, ids(domain.AuditQuery{Since: &past, Until: &future}), 4)
+	assert.Empty(t, ids(domain.AuditQuery{Since: &future}))
+	assert.Empty(t, ids(domain.AuditQuery{Until: &past}))
+
+	entries, err := repo.ListAudit(ctx, domain.AuditQuery{Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		entry := entries[0]
+		assert.Equal(t, "api_key:1", entry.Actor)
+		assert.Equal(t, domain.AuditDelete, entry.Action)
+		assert.Equal(t, "req-1", entry.RequestID)
+		assert.Equal(t, map[string]domain.Change{"stock": {Before: 1.0, After: 2.0}}, entry.Changes)
+		assert.False(t, entry.CreatedAt.IsZero())
+	}
+}
+
+func TestAuditRepositoryMemory(t *testing.T) {
+	testAuditRepositoryContract(t, infrastucture.NewAuditRepositoryMemory())
+}
+
+func TestAuditRepositorySQLite(t *testing.T) {
+	testAuditRepositoryContract(t, infrastucture.NewAuditRepositorySQLite(newSQLiteDB(t)))
+}
+
+func TestSQLTransactor(t *testing.T) {
+	db := newSQLiteDB(t)
+	books := infrastucture.NewBookRepositorySQLite(db)
+	audits := infrastucture.NewAuditRepositorySQLite(db)
+	tx := infrastucture.NewSQLTransactor(db)
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := books.CreateBook(ctx, &domain.Book{Title: "Test Title 1", Author: "Test Author 1", Price: domain.NewMoney(100, "USD")}); err != nil {
+			return err
+		}
+		if err := audits.RecordAudit(ctx, &domain.AuditEntry{Actor: "system", Action: domain.AuditCreate, BookID: 1}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	all, err := books.GetAll(ctx, domain.BookQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+	entries, err := audits.ListAudit(ctx, domain.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	err = tx.WithinTx(ctx, func(ctx context.Context) error {
+		// Nested calls join the outer transaction.
+		return tx.WithinTx(ctx, func(ctx context.Context) error {
+			created, err := books.CreateBook(ctx, &domain.Book{Title: "Test Title 2", Author: "Test Author 2", Price: domain.NewMoney(100, "USD")})
+			if err != nil {
+				return err
+			}
+			if _, err := books.UpdateBook(ctx, &domain.Book{Title: "Test Title 2", Author: "Test Author 2", Price: domain.NewMoney(100, "USD"), Stock: 4}, created.ID); err != nil {
+				return err
+			}
+			return audits.RecordAudit(ctx, &domain.AuditEntry{Actor: "system", Action: domain.AuditCreate, BookID: created.ID})
+		})
+	})
+	assert.NoError(t, err)
+	all, err = books.GetAll(ctx, domain.BookQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, all, 1) {
+		assert.Equal(t, 4, all[0].Stock)
+	}
+	entries, err = audits.ListAudit(ctx, domain.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,245 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sqlDialect struct {
+	placeholder func(n int) string
+	timeArg     func(t time.Time) any
+	// returning is set for databases that hand back generated IDs through
+	// INSERT ... RETURNING rather than LastInsertId.
+	returning bool
+	// ignoreConflict is appended to an INSERT so that a duplicate key on
+	// column inserts nothing rather than failing.
+	ignoreConflict func(column string) string
+	// forUpdate locks the rows a SELECT reads. SQLite has no row locks and
+	// takes its write lock when the transaction begins.
+	forUpdate string
+}
+
+func onConflictDoNothing(string) string { return ` ON CONFLICT DO NOTHING` }
+
+var (
+	mysqlDialect = sqlDialect{
+		placeholder: func(int) string { return "?" },
+		timeArg:     func(t time.Time) any { return t },
+		// Assigning a column to itself leaves the row unchanged and reports
+		// no rows affected.
+		ignoreConflict: func(column string) string { return ` ON DUPLICATE KEY UPDATE ` + column + ` = ` + column },
+		forUpdate:      ` FOR UPDATE`,
+	}
+	// SQLite keeps CURRENT_TIMESTAMP values as text, so cursors must compare
+	// against the same layout.
+	sqliteDialect = sqlDialect{
+		placeholder:    func(int) string { return "?" },
+		timeArg:        func(t time.Time) any { return t.UTC().Format("2006-01-02 15:04:05") },
+		ignoreConflict: onConflictDoNothing,
+	}
+	postgresDialect = sqlDialect{
+		placeholder:    func(n int) string { return "$" + strconv.Itoa(n) },
+		timeArg:        func(t time.Time) any { return t },
+		returning:      true,
+		ignoreConflict: onConflictDoNothing,
+		forUpdate:      ` FOR UPDATE`,
+	}
+)
+
+type queryBuilder struct {
+	dialect sqlDialect
+	args    []any
+}
+
+func (b *queryBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return b.dialect.placeholder(len(b.args))
+}
+
+func sortValue(d sqlDialect, sort string, book *domain.Book) any {
+	switch sort {
+	case domain.SortByTitle:
+		return book.Title
+	case domain.SortByAuthor:
+		return book.Author
+	case domain.SortByPrice:
+		return book.Price.Amount
+	case domain.SortByCreatedAt:
+		return d.timeArg(book.CreatedAt)
+	default:
+		return book.ID
+	}
+}
+
+// sortColumn whitelists the sort field since it is interpolated into SQL.
+func sortColumn(sort string) string {
+	switch sort {
+	case domain.SortByTitle, domain.SortByAuthor, domain.SortByPrice, domain.SortByCreatedAt:
+		return sort
+	default:
+		return domain.SortByID
+	}
+}
+
+// buildBookQuery renders q as a keyset-paginated SELECT over books.
+func buildBookQuery(d sqlDialect, q domain.BookQuery) (string, []any) {
+	b := &queryBuilder{dialect: d}
+	where := []string{"deleted_at IS NULL"}
+	if q.Deleted {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+
+	if q.Genre != "" {
+		where = append(where, "genre = "+b.arg(q.Genre))
+	}
+	if q.Author != "" {
+		where = append(where, "author = "+b.arg(q.Author))
+	}
+	if q.Currency != "" {
+		where = append(where, "currency = "+b.arg(q.Currency))
+	}
+	if q.MinPrice != nil {
+		where = append(where, "price >= "+b.arg(*q.MinPrice))
+	}
+	if q.MaxPrice != nil {
+		where = append(where, "price <= "+b.arg(*q.MaxPrice))
+	}
+	if q.InStock {
+		where = append(where, "stock > 0")
+	}
+
+	sort := sortColumn(q.Sort)
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if q.After != nil {
+		if sort == domain.SortByID {
+			where = append(where, fmt.Sprintf("id %s %s", op, b.arg(q.After.ID)))
+		} else {
+			value := sortValue(d, sort, q.After)
+			where = append(where, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))",
+				sort, op, b.arg(value), sort, b.arg(value), op, b.arg(q.After.ID)))
+		}
+	}
+
+	query := `SELECT ` + bookColumns + ` FROM books WHERE ` + strings.Join(where, " AND ")
+	if sort == domain.SortByID {
+		query += fmt.Sprintf(" ORDER BY id %s", dir)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", sort, dir, dir)
+	}
+	if q.Limit > 0 {
+		query += " LIMIT " + b.arg(q.Limit)
+	}
+	return query, b.args
+}
+
+// patchAssignments renders the SET list for the columns patch changes. A
+// price change writes its currency too.
+func patchAssignments(b *queryBuilder, patch domain.BookPatch) []string {
+	var set []string
+	if patch.Title != nil {
+		set = append(set, "title="+b.arg(*patch.Title))
+	}
+	if patch.Author != nil {
+		set = append(set, "author="+b.arg(*patch.Author))
+	}
+	if patch.Genre != nil {
+		set = append(set, "genre="+b.arg(*patch.Genre))
+	}
+	if patch.Price != nil {
+		set = append(set, "price="+b.arg(patch.Price.Amount), "currency="+b.arg(patch.Price.Currency))
+	}
+	if patch.Stock != nil {
+		set = append(set, "stock="+b.arg(*patch.Stock))
+	}
+	if patch.ISBN != nil {
+		set = append(set, "isbn="+b.arg(*patch.ISBN))
+	}
+	return set
+}
+
+// replacement is the patch that overwrites every field with book's, so full
+// updates share the conditional write path with patches.
+func replacement(book *domain.Book) domain.BookPatch {
+	return domain.BookPatch{
+		Version: book.Version,
+		Title:   &book.Title,
+		Author:  &book.Author,
+		Genre:   &book.Genre,
+		Price:   &book.Price,
+		Stock:   &book.Stock,
+		ISBN:    &book.ISBN,
+	}
+}
+
+// versionCondition renders the WHERE clause for a write to live book ID. It
+// only matches the expected version unless version is 0.
+func versionCondition(b *queryBuilder, ID, version int) string {
+	where := " WHERE id=" + b.arg(ID) + " AND deleted_at IS NULL"
+	if version != 0 {
+		where += " AND version=" + b.arg(version)
+	}
+	return where
+}
+
+// currentBook answers a patch that changes nothing from the stored book.
+func currentBook(ctx context.Context, repo domain.BookRepository, ID, version int) (*domain.Book, error) {
+	book, err := repo.GetBook(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && book.Version != version {
+		return nil, &domain.StaleError{Resource: "book", ID: ID}
+	}
+	return &book, nil
+}
+
+// purgeDeleted hard-deletes books that went to the trash before the cutoff
+// and returns their IDs.
+func purgeDeleted(ctx context.Context, db *sql.DB, d sqlDialect, before time.Time) ([]int, error) {
+	tx, err := beginTx(ctx, db)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer tx.Rollback()
+
+	// The books are locked as they are listed so none is restored before
+	// it is removed.
+	b := &queryBuilder{dialect: d}
+	where := ` WHERE deleted_at IS NOT NULL AND deleted_at < ` + b.arg(d.timeArg(before))
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM books`+where+` ORDER BY id`+d.forUpdate, b.args...)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+	var IDs []int
+	for rows.Next() {
+		var ID int
+		if err := rows.Scan(&ID); err != nil {
+			return nil, bookError(err, 0)
+		}
+		IDs = append(IDs, ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	if len(IDs) == 0 {
+		return nil, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM books`+where, b.args...); err != nil {
+		return nil, bookError(err, 0)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	return IDs, nil
+}
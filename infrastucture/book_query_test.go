@@ -0,0 +1,66 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildBookQuery(t *testing.T) {
+	min := int64(100)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type testCase struct {
+		name     string
+		dialect  sqlDialect
+		query    domain.BookQuery
+		expected string
+		args     []any
+	}
+
+	tests := []testCase{
+		{
+			name:     "defaults",
+			dialect:  mysqlDialect,
+			query:    domain.BookQuery{},
+			expected: "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL ORDER BY id ASC",
+		},
+		{
+			name:     "filters and limit",
+			dialect:  mysqlDialect,
+			query:    domain.BookQuery{Genre: "Horror", MinPrice: &min, InStock: true, Limit: 11},
+			expected: "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL AND genre = ? AND price >= ? AND stock > 0 ORDER BY id ASC LIMIT ?",
+			args:     []any{"Horror", int64(100), 11},
+		},
+		{
+			name:     "keyset on title descending",
+			dialect:  postgresDialect,
+			query:    domain.BookQuery{Author: "King", Sort: domain.SortByTitle, Desc: true, After: &domain.Book{ID: 4, Title: "It"}, Limit: 3},
+			expected: "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL AND author = $1 AND (title < $2 OR (title = $3 AND id < $4)) ORDER BY title DESC, id DESC LIMIT $5",
+			args:     []any{"King", "It", "It", 4, 3},
+		},
+		{
+			name:     "sqlite created_at cursor",
+			dialect:  sqliteDialect,
+			query:    domain.BookQuery{Sort: domain.SortByCreatedAt, After: &domain.Book{ID: 2, CreatedAt: created}},
+			expected: "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL AND (created_at > ? OR (created_at = ? AND id > ?)) ORDER BY created_at ASC, id ASC",
+			args:     []any{"2024-01-02 03:04:05", "2024-01-02 03:04:05", 2},
+		},
+		{
+			name:     "unknown sort falls back to id",
+			dialect:  mysqlDialect,
+			query:    domain.BookQuery{Sort: "stock; DROP TABLE books"},
+			expected: "SELECT " + bookColumns + " FROM books WHERE deleted_at IS NULL ORDER BY id ASC",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args := buildBookQuery(tc.dialect, tc.query)
+			assert.Equal(t, tc.expected, query)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
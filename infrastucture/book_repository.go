@@ -2,10 +2,14 @@ package infrastucture
 
 import (
 	"book-apis/domain"
+	"context"
 	"database/sql"
-	"errors"
+	"strings"
+	"time"
 )
 
+const bookColumns = `id, title, author, genre, price, currency, stock, isbn, created_at, updated_at, version, deleted_at`
+
 type BookRepositoryDB struct {
 	DB *sql.DB
 }
@@ -14,64 +18,183 @@ func NewBookRepositoryDB(db *sql.DB) *BookRepositoryDB {
 	return &BookRepositoryDB{DB: db}
 }
 
-func (r *BookRepositoryDB) GetAll() ([]domain.Book, error) {
-	rows, err := r.DB.Query(`SELECT title, author, genre, price, stock FROM books`)
+// scanBook reads bookColumns followed by any extra selected columns.
+func scanBook(row interface{ Scan(...any) error }, extra ...any) (domain.Book, error) {
+	var book domain.Book
+	dest := []any{&book.ID, &book.Title, &book.Author, &book.Genre, &book.Price, &book.Price.Currency, &book.Stock, &book.ISBN, &book.CreatedAt, &book.UpdatedAt, &book.Version, &book.DeletedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return book, err
+}
+
+func (r *BookRepositoryDB) GetAll(ctx context.Context, q domain.BookQuery) ([]domain.Book, error) {
+	query, args := buildBookQuery(mysqlDialect, q)
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, bookError(err, 0)
 	}
 
 	defer rows.Close()
 
 	var books []domain.Book
 	for rows.Next() {
-		book := domain.Book{}
-		if err := rows.Scan(&book.Title, &book.Author, &book.Genre, &book.Price, &book.Stock); err != nil {
-			return nil, err
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, bookError(err, 0)
 		}
 		books = append(books, book)
 	}
-	return books, nil
+	return books, bookError(rows.Err(), 0)
 }
 
-func (r *BookRepositoryDB) GetBook(ID int) (domain.Book, error) {
-	row := r.DB.QueryRow(`SELECT title, author, genre, price, stock FROM books WHERE id = ?`, ID)
-	var book domain.Book
-	if err := row.Scan(&book.Title, &book.Author, &book.Genre, &book.Price, &book.Stock); err != nil {
-		return domain.Book{}, err
+func (r *BookRepositoryDB) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ? AND deleted_at IS NULL`, ID))
+	if err != nil {
+		return domain.Book{}, bookError(err, ID)
 	}
 	return book, nil
 }
 
-func (r *BookRepositoryDB) CreateBook(newBook *domain.Book) (*domain.Book, error) {
-	book := &domain.Book{}
-	err := r.DB.QueryRow(`INSERT INTO books VALUES(?,?,?,?,?)`, newBook.Title, newBook.Author, newBook.Genre, newBook.Price, newBook.Stock).Scan(&book.Title, &book.Author, &book.Genre, &book.Price, &book.Stock)
+func (r *BookRepositoryDB) CreateBook(ctx context.Context, newBook *domain.Book) (*domain.Book, error) {
+	tx, err := beginTx(ctx, r.DB)
 	if err != nil {
-		return nil, err
+		return nil, bookError(err, 0)
 	}
-	return book, err
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO books (title, author, genre, price, currency, stock, isbn) VALUES (?, ?, ?, ?, ?, ?, ?)`, newBook.Title, newBook.Author, newBook.Genre, newBook.Price, newBook.Price.Currency, newBook.Stock, newBook.ISBN)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	ID, err := result.LastInsertId()
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+
+	book, err := scanBook(tx.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ?`, ID))
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositoryDB) UpdateBook(ctx context.Context, updateBook *domain.Book, ID int) (*domain.Book, error) {
+	return r.PatchBook(ctx, replacement(updateBook), ID)
 }
 
-func (r *BookRepositoryDB) UpdateBook(updateBook *domain.Book, ID int) (*domain.Book, error) {
-	book := &domain.Book{}
-	err := r.DB.QueryRow(`UPDATE books SET title=?, author=?, genre=?, price=?, stock=? WHERE id=?`, updateBook.Title, updateBook.Author, updateBook.Genre, updateBook.Price, updateBook.Stock, ID).Scan(&book.Title, &book.Author, &book.Genre, &book.Price, &book.Stock)
+func (r *BookRepositoryDB) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	if patch.IsEmpty() {
+		return currentBook(ctx, r, ID, patch.Version)
+	}
+
+	tx, err := beginTx(ctx, r.DB)
 	if err != nil {
-		return nil, err
+		return nil, bookError(err, ID)
 	}
-	return book, nil
+	defer tx.Rollback()
+
+	b := &queryBuilder{dialect: mysqlDialect}
+	set := append(patchAssignments(b, patch), "version=version+1")
+	query := `UPDATE books SET ` + strings.Join(set, ", ") + versionCondition(b, ID, patch.Version)
+	result, err := tx.ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	// The version bump means a matched row always counts as affected.
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	if rowsAffected == 0 {
+		return nil, missedWrite(tx.QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = ? AND deleted_at IS NULL`, ID), ID)
+	}
+
+	book, err := scanBook(tx.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ?`, ID))
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
 }
 
-func (r *BookRepositoryDB) DeleteBook(ID int) error {
-	result, err := r.DB.Exec(`DELETE FROM books WHERE id=?`, ID)
+func (r *BookRepositoryDB) DeleteBook(ctx context.Context, ID int, version int) error {
+	b := &queryBuilder{dialect: mysqlDialect}
+	query := `UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version+1` + versionCondition(b, ID, version)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
 	if err != nil {
-		return err
+		return bookError(err, ID)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return bookError(err, ID)
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("no rows were deleted")
+		return missedWrite(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = ? AND deleted_at IS NULL`, ID), ID)
 	}
 	return nil
 }
+
+func (r *BookRepositoryDB) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	tx, err := beginTx(ctx, r.DB)
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE books SET deleted_at=NULL, version=version+1 WHERE id=? AND deleted_at IS NOT NULL`, ID)
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	if rowsAffected == 0 {
+		return nil, &domain.NotFoundError{Resource: "deleted book", ID: ID}
+	}
+
+	book, err := scanBook(tx.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ?`, ID))
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositoryDB) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	return purgeDeleted(ctx, r.DB, mysqlDialect, before)
+}
+
+func (r *BookRepositoryDB) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	against := "+" + strings.Join(terms, "* +") + "*"
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT `+bookColumns+`, MATCH(title, author, genre) AGAINST (? IN BOOLEAN MODE) AS score FROM books WHERE MATCH(title, author, genre) AGAINST (? IN BOOLEAN MODE) AND deleted_at IS NULL ORDER BY score DESC, id LIMIT ?`, against, against, limit)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+
+	var results []domain.SearchResult
+	for rows.Next() {
+		var result domain.SearchResult
+		if result.Book, err = scanBook(rows, &result.Score); err != nil {
+			return nil, bookError(err, 0)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	return withSnippets(results, terms), nil
+}
@@ -0,0 +1,293 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBookRepositoryContract checks the behaviour every BookRepository
+// implementation must share, against a fresh empty store.
+func testBookRepositoryContract(t *testing.T, newRepo func(t *testing.T) domain.BookRepository) {
+	t.Run("CRUD", func(t *testing.T) {
+		repo := newRepo(t)
+
+		books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+		assert.NoError(t, err)
+		assert.Empty(t, books)
+
+		created, err := repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, created.ID)
+		assert.Equal(t, "Test Title 1", created.Title)
+		assert.False(t, created.CreatedAt.IsZero())
+		assert.False(t, created.UpdatedAt.IsZero())
+
+		second, err := repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, second.ID)
+
+		book, err := repo.GetBook(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, created.ID, book.ID)
+		assert.Equal(t, created.Title, book.Title)
+		assert.True(t, created.CreatedAt.Equal(book.CreatedAt))
+
+		updated, err := repo.UpdateBook(context.Background(), &domain.Book{Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 5}, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, updated.ID)
+		assert.Equal(t, "Updated Test Title 1", updated.Title)
+		assert.Equal(t, 5, updated.Stock)
+		assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+
+		books, err = repo.GetAll(context.Background(), domain.BookQuery{})
+		assert.NoError(t, err)
+		if assert.Len(t, books, 2) {
+			assert.Equal(t, 1, books[0].ID)
+			assert.Equal(t, 2, books[1].ID)
+		}
+
+		assert.NoError(t, repo.DeleteBook(context.Background(), 1, 0))
+		_, err = repo.GetBook(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetBook(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		result, err := repo.UpdateBook(context.Background(), &domain.Book{Title: "Test Title 1"}, 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.Nil(t, result)
+
+		err = repo.DeleteBook(context.Background(), 1, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.EqualError(t, err, "book 1 not found")
+	})
+	t.Run("Patch", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD"), Stock: 3, ISBN: "9780441013593"})
+		assert.NoError(t, err)
+
+		stock, price := 9, domain.NewMoney(1200, "EUR")
+		patched, err := repo.PatchBook(context.Background(), domain.BookPatch{Stock: &stock, Price: &price}, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Dune", patched.Title)
+		assert.Equal(t, "Herbert", patched.Author)
+		assert.Equal(t, "9780441013593", patched.ISBN)
+		assert.Equal(t, 9, patched.Stock)
+		assert.Equal(t, price, patched.Price)
+		assert.True(t, created.CreatedAt.Equal(patched.CreatedAt))
+
+		book, err := repo.GetBook(context.Background(), created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, *patched, book)
+
+		unchanged, err := repo.PatchBook(context.Background(), domain.BookPatch{}, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, book, *unchanged)
+
+		_, err = repo.PatchBook(context.Background(), domain.BookPatch{Stock: &stock}, 99)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.PatchBook(context.Background(), domain.BookPatch{}, 99)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Herbert", Price: domain.NewMoney(1500, "USD"), Stock: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, created.Version)
+
+		update := *created
+		update.Stock = 4
+		updated, err := repo.UpdateBook(context.Background(), &update, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, updated.Version)
+
+		// Writers still holding version 1 lose.
+		_, err = repo.UpdateBook(context.Background(), &update, created.ID)
+		assert.ErrorIs(t, err, domain.ErrStale)
+		stock := 5
+		_, err = repo.PatchBook(context.Background(), domain.BookPatch{Version: 1, Stock: &stock}, created.ID)
+		assert.ErrorIs(t, err, domain.ErrStale)
+		_, err = repo.PatchBook(context.Background(), domain.BookPatch{Version: 1}, created.ID)
+		assert.ErrorIs(t, err, domain.ErrStale)
+		assert.ErrorIs(t, repo.DeleteBook(context.Background(), created.ID, 1), domain.ErrStale)
+
+		patched, err := repo.PatchBook(context.Background(), domain.BookPatch{Version: 2, Stock: &stock}, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, patched.Version)
+		assert.Equal(t, 5, patched.Stock)
+
+		assert.ErrorIs(t, repo.DeleteBook(context.Background(), 99, 1), domain.ErrNotFound)
+		assert.NoError(t, repo.DeleteBook(context.Background(), created.ID, 3))
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, title := range []string{"Dune", "Emma"} {
+			_, err := repo.CreateBook(context.Background(), &domain.Book{Title: title, Author: "Author", Price: domain.NewMoney(1500, "USD")})
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, repo.DeleteBook(context.Background(), 1, 1))
+		_, err := repo.GetBook(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.UpdateBook(context.Background(), &domain.Book{Title: "Dune"}, 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteBook(context.Background(), 1, 0), domain.ErrNotFound)
+		results, err := repo.Search(context.Background(), "dune", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+
+		books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+		assert.NoError(t, err)
+		if assert.Len(t, books, 1) {
+			assert.Equal(t, 2, books[0].ID)
+			assert.Nil(t, books[0].DeletedAt)
+		}
+		trash, err := repo.GetAll(context.Background(), domain.BookQuery{Deleted: true})
+		assert.NoError(t, err)
+		if assert.Len(t, trash, 1) {
+			assert.Equal(t, 1, trash[0].ID)
+			assert.NotNil(t, trash[0].DeletedAt)
+			assert.Equal(t, 2, trash[0].Version)
+		}
+
+		restored, err := repo.RestoreBook(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, 3, restored.Version)
+		_, err = repo.RestoreBook(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.GetBook(context.Background(), 1)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.DeleteBook(context.Background(), 1, 3))
+		IDs, err := repo.PurgeDeleted(context.Background(), time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Empty(t, IDs)
+		IDs, err = repo.PurgeDeleted(context.Background(), time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1}, IDs)
+
+		trash, err = repo.GetAll(context.Background(), domain.BookQuery{Deleted: true})
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+		_, err = repo.RestoreBook(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = repo.GetBook(context.Background(), 2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		repo := newRepo(t)
+		seed := []domain.Book{
+			{Title: "Dune", Author: "Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD"), Stock: 3},
+			{Title: "Carrie", Author: "King", Genre: "Horror", Price: domain.NewMoney(900, "USD"), Stock: 0},
+			{Title: "It", Author: "King", Genre: "Horror", Price: domain.NewMoney(1500, "USD"), Stock: 7},
+			{Title: "Emma", Author: "Austen", Genre: "Romance", Price: domain.NewMoney(700, "USD"), Stock: 1},
+			{Title: "Anathem", Author: "Stephenson", Genre: "SciFi", Price: domain.NewMoney(2000, "EUR"), Stock: 2},
+		}
+		for i := range seed {
+			_, err := repo.CreateBook(context.Background(), &seed[i])
+			assert.NoError(t, err)
+		}
+
+		ids := func(books []domain.Book) []int {
+			var out []int
+			for _, b := range books {
+				out = append(out, b.ID)
+			}
+			return out
+		}
+		min, max := int64(900), int64(1500)
+
+		type testCase struct {
+			name     string
+			query    domain.BookQuery
+			expected []int
+		}
+		tests := []testCase{
+			{name: "default order", query: domain.BookQuery{}, expected: []int{1, 2, 3, 4, 5}},
+			{name: "limit", query: domain.BookQuery{Limit: 2}, expected: []int{1, 2}},
+			{name: "after id", query: domain.BookQuery{After: &domain.Book{ID: 2}}, expected: []int{3, 4, 5}},
+			{name: "id descending", query: domain.BookQuery{Desc: true, Limit: 2}, expected: []int{5, 4}},
+			{name: "title", query: domain.BookQuery{Sort: domain.SortByTitle}, expected: []int{5, 2, 1, 4, 3}},
+			{name: "title after cursor", query: domain.BookQuery{Sort: domain.SortByTitle, After: &domain.Book{ID: 2, Title: "Carrie"}}, expected: []int{1, 4, 3}},
+			{name: "price ties broken by id", query: domain.BookQuery{Sort: domain.SortByPrice, Currency: "USD"}, expected: []int{4, 2, 1, 3}},
+			{name: "price descending after tie", query: domain.BookQuery{Sort: domain.SortByPrice, Desc: true, Currency: "USD", After: &domain.Book{ID: 3, Price: domain.NewMoney(1500, "USD")}}, expected: []int{1, 2, 4}},
+			{name: "author", query: domain.BookQuery{Sort: domain.SortByAuthor, Limit: 3}, expected: []int{4, 1, 2}},
+			{name: "genre", query: domain.BookQuery{Genre: "Horror"}, expected: []int{2, 3}},
+			{name: "author filter", query: domain.BookQuery{Author: "King"}, expected: []int{2, 3}},
+			{name: "price range", query: domain.BookQuery{MinPrice: &min, MaxPrice: &max}, expected: []int{1, 2, 3}},
+			{name: "in stock", query: domain.BookQuery{InStock: true, Genre: "Horror"}, expected: []int{3}},
+			{name: "created_at", query: domain.BookQuery{Sort: domain.SortByCreatedAt, Limit: 2}, expected: []int{1, 2}},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				books, err := repo.GetAll(context.Background(), tc.query)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, ids(books))
+			})
+		}
+	})
+	t.Run("Search", func(t *testing.T) {
+		repo := newRepo(t)
+		seed := []domain.Book{
+			{Title: "The Shining", Author: "Stephen King", Genre: "Horror", Price: domain.NewMoney(900, "USD")},
+			{Title: "King Rat", Author: "James Clavell", Genre: "Fiction", Price: domain.NewMoney(1200, "USD")},
+			{Title: "Dune", Author: "Frank Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD")},
+		}
+		for i := range seed {
+			_, err := repo.CreateBook(context.Background(), &seed[i])
+			assert.NoError(t, err)
+		}
+
+		results, err := repo.Search(context.Background(), "king", 10)
+		assert.NoError(t, err)
+		if assert.Len(t, results, 2) {
+			// A title match outranks an author match.
+			assert.Equal(t, 2, results[0].Book.ID)
+			assert.Equal(t, 1, results[1].Book.ID)
+			assert.Greater(t, results[0].Score, results[1].Score)
+			assert.Equal(t, "<mark>King</mark> Rat — James Clavell (Fiction)", results[0].Snippet)
+			assert.Equal(t, "The Shining — Stephen <mark>King</mark> (Horror)", results[1].Snippet)
+		}
+
+		results, err = repo.Search(context.Background(), "shin ste", 10)
+		assert.NoError(t, err)
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, 1, results[0].Book.ID)
+		}
+
+		results, err = repo.Search(context.Background(), "king", 1)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		results, err = repo.Search(context.Background(), "herb* OR \"", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+
+		_, err = repo.UpdateBook(context.Background(), &domain.Book{Title: "Dune Messiah", Author: "Frank Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD")}, 3)
+		assert.NoError(t, err)
+		results, err = repo.Search(context.Background(), "messiah", 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		assert.NoError(t, repo.DeleteBook(context.Background(), 3, 0))
+		results, err = repo.Search(context.Background(), "messiah", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+
+		results, err = repo.Search(context.Background(), "  ", 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+}
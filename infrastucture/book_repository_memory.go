@@ -0,0 +1,216 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type BookRepositoryMemory struct {
+	mu     sync.RWMutex
+	books  map[int]domain.Book
+	nextID int
+	now    func() time.Time
+}
+
+func NewBookRepositoryMemory() *BookRepositoryMemory {
+	return &BookRepositoryMemory{
+		books:  map[int]domain.Book{},
+		nextID: 1,
+		now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (r *BookRepositoryMemory) GetAll(ctx context.Context, q domain.BookQuery) ([]domain.Book, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sort := sortColumn(q.Sort)
+	order := func(a, b *domain.Book) int {
+		c := compareBooks(sort, a, b)
+		if q.Desc {
+			return -c
+		}
+		return c
+	}
+
+	var books []domain.Book
+	for _, book := range r.books {
+		if !matchesBookQuery(q, &book) {
+			continue
+		}
+		if q.After != nil && order(&book, q.After) <= 0 {
+			continue
+		}
+		books = append(books, book)
+	}
+	slices.SortFunc(books, func(a, b domain.Book) int { return order(&a, &b) })
+	if q.Limit > 0 && len(books) > q.Limit {
+		books = books[:q.Limit]
+	}
+	return books, nil
+}
+
+func matchesBookQuery(q domain.BookQuery, book *domain.Book) bool {
+	switch {
+	case q.Deleted != (book.DeletedAt != nil):
+		return false
+	case q.Genre != "" && book.Genre != q.Genre:
+		return false
+	case q.Author != "" && book.Author != q.Author:
+		return false
+	case q.Currency != "" && book.Price.Currency != q.Currency:
+		return false
+	case q.MinPrice != nil && book.Price.Amount < *q.MinPrice:
+		return false
+	case q.MaxPrice != nil && book.Price.Amount > *q.MaxPrice:
+		return false
+	case q.InStock && book.Stock <= 0:
+		return false
+	}
+	return true
+}
+
+// compareBooks orders by the sort field with the ID as tie-breaker, the same
+// order buildBookQuery asks of the database.
+func compareBooks(sort string, a, b *domain.Book) int {
+	var c int
+	switch sort {
+	case domain.SortByTitle:
+		c = strings.Compare(a.Title, b.Title)
+	case domain.SortByAuthor:
+		c = strings.Compare(a.Author, b.Author)
+	case domain.SortByPrice:
+		c = cmp.Compare(a.Price.Amount, b.Price.Amount)
+	case domain.SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	return c
+}
+
+func (r *BookRepositoryMemory) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current(ID, 0)
+}
+
+func (r *BookRepositoryMemory) CreateBook(ctx context.Context, newBook *domain.Book) (*domain.Book, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	book := *newBook
+	book.ID = r.nextID
+	book.DeletedAt = nil
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.Version = 1
+	r.books[book.ID] = book
+	r.nextID++
+	return &book, nil
+}
+
+func (r *BookRepositoryMemory) UpdateBook(ctx context.Context, updateBook *domain.Book, ID int) (*domain.Book, error) {
+	return r.PatchBook(ctx, replacement(updateBook), ID)
+}
+
+func (r *BookRepositoryMemory) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	book, err := r.current(ID, patch.Version)
+	if err != nil {
+		return nil, err
+	}
+	if patch.IsEmpty() {
+		return &book, nil
+	}
+	patch.Apply(&book)
+	book.UpdatedAt = r.now()
+	book.Version++
+	r.books[ID] = book
+	return &book, nil
+}
+
+// current returns live book ID if it is at version, or at any version when
+// version is 0. The caller must hold r.mu.
+func (r *BookRepositoryMemory) current(ID, version int) (domain.Book, error) {
+	book, ok := r.books[ID]
+	if !ok || book.DeletedAt != nil {
+		return domain.Book{}, &domain.NotFoundError{Resource: "book", ID: ID}
+	}
+	if version != 0 && book.Version != version {
+		return domain.Book{}, &domain.StaleError{Resource: "book", ID: ID}
+	}
+	return book, nil
+}
+
+func (r *BookRepositoryMemory) DeleteBook(ctx context.Context, ID int, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	book, err := r.current(ID, version)
+	if err != nil {
+		return err
+	}
+	now := r.now()
+	book.DeletedAt = &now
+	book.Version++
+	r.books[ID] = book
+	return nil
+}
+
+func (r *BookRepositoryMemory) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	book, ok := r.books[ID]
+	if !ok || book.DeletedAt == nil {
+		return nil, &domain.NotFoundError{Resource: "deleted book", ID: ID}
+	}
+	book.DeletedAt = nil
+	book.Version++
+	r.books[ID] = book
+	return &book, nil
+}
+
+func (r *BookRepositoryMemory) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var IDs []int
+	for ID, book := range r.books {
+		if book.DeletedAt != nil && book.DeletedAt.Before(before) {
+			delete(r.books, ID)
+			IDs = append(IDs, ID)
+		}
+	}
+	slices.Sort(IDs)
+	return IDs, nil
+}
+
+func (r *BookRepositoryMemory) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	r.mu.RLock()
+	books := make([]domain.Book, 0, len(r.books))
+	for _, book := range r.books {
+		if book.DeletedAt == nil {
+			books = append(books, book)
+		}
+	}
+	r.mu.RUnlock()
+
+	return rankBooks(books, terms, limit), nil
+}
@@ -0,0 +1,59 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookRepositoryMemory(t *testing.T) {
+	testBookRepositoryContract(t, func(t *testing.T) domain.BookRepository {
+		return infrastucture.NewBookRepositoryMemory()
+	})
+}
+
+func TestBookRepositoryMemory_ConcurrentCreate(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title"})
+		}()
+	}
+	wg.Wait()
+
+	books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, books, 50)
+	for i, book := range books {
+		assert.Equal(t, i+1, book.ID)
+	}
+}
+
+func TestBookRepositoryMemory_WritesIgnoreDeletedAt(t *testing.T) {
+	ctx := context.Background()
+	repo := infrastucture.NewBookRepositoryMemory()
+	deleted := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	created, err := repo.CreateBook(ctx, &domain.Book{Title: "Test Title", DeletedAt: &deleted})
+	assert.NoError(t, err)
+	assert.Nil(t, created.DeletedAt)
+	updated, err := repo.UpdateBook(ctx, &domain.Book{Title: "Test Title 2", DeletedAt: &deleted}, created.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, updated.DeletedAt)
+
+	book, err := repo.GetBook(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Title 2", book.Title)
+	IDs, err := repo.PurgeDeleted(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, IDs)
+}
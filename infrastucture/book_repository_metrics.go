@@ -0,0 +1,128 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BookRepositoryMetrics decorates another BookRepository with per-method
+// latency histograms and error counters.
+type BookRepositoryMetrics struct {
+	repo     domain.BookRepository
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func NewBookRepositoryMetrics(repo domain.BookRepository, reg prometheus.Registerer) *BookRepositoryMetrics {
+	m := &BookRepositoryMetrics{
+		repo: repo,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "books",
+			Name:      "repository_call_duration_seconds",
+			Help:      "Time taken by book repository calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "books",
+			Name:      "repository_errors_total",
+			Help:      "Book repository calls that returned an error, by kind.",
+		}, []string{"method", "kind"}),
+	}
+	reg.MustRegister(m.duration, m.errors)
+	return m
+}
+
+// errorKind buckets errors by the domain sentinel they match so the label
+// stays bounded.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, domain.ErrConflict):
+		return "conflict"
+	case errors.Is(err, domain.ErrStale):
+		return "stale"
+	case errors.Is(err, domain.ErrValidation):
+		return "validation"
+	case errors.Is(err, domain.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, domain.ErrTimeout):
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
+
+func (m *BookRepositoryMetrics) observe(method string, start time.Time, err error) {
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(method, errorKind(err)).Inc()
+	}
+}
+
+func (m *BookRepositoryMetrics) GetAll(ctx context.Context, query domain.BookQuery) ([]domain.Book, error) {
+	start := time.Now()
+	books, err := m.repo.GetAll(ctx, query)
+	m.observe("GetAll", start, err)
+	return books, err
+}
+
+func (m *BookRepositoryMetrics) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	start := time.Now()
+	book, err := m.repo.GetBook(ctx, ID)
+	m.observe("GetBook", start, err)
+	return book, err
+}
+
+func (m *BookRepositoryMetrics) CreateBook(ctx context.Context, book *domain.Book) (*domain.Book, error) {
+	start := time.Now()
+	created, err := m.repo.CreateBook(ctx, book)
+	m.observe("CreateBook", start, err)
+	return created, err
+}
+
+func (m *BookRepositoryMetrics) UpdateBook(ctx context.Context, book *domain.Book, ID int) (*domain.Book, error) {
+	start := time.Now()
+	updated, err := m.repo.UpdateBook(ctx, book, ID)
+	m.observe("UpdateBook", start, err)
+	return updated, err
+}
+
+func (m *BookRepositoryMetrics) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	start := time.Now()
+	book, err := m.repo.PatchBook(ctx, patch, ID)
+	m.observe("PatchBook", start, err)
+	return book, err
+}
+
+func (m *BookRepositoryMetrics) DeleteBook(ctx context.Context, ID int, version int) error {
+	start := time.Now()
+	err := m.repo.DeleteBook(ctx, ID, version)
+	m.observe("DeleteBook", start, err)
+	return err
+}
+
+func (m *BookRepositoryMetrics) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	start := time.Now()
+	results, err := m.repo.Search(ctx, query, limit)
+	m.observe("Search", start, err)
+	return results, err
+}
+
+func (m *BookRepositoryMetrics) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	start := time.Now()
+	book, err := m.repo.RestoreBook(ctx, ID)
+	m.observe("RestoreBook", start, err)
+	return book, err
+}
+
+func (m *BookRepositoryMetrics) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	start := time.Now()
+	IDs, err := m.repo.PurgeDeleted(ctx, before)
+	m.observe("PurgeDeleted", start, err)
+	return IDs, err
+}
@@ -0,0 +1,37 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookRepositoryMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	repo := infrastucture.NewBookRepositoryMetrics(infrastucture.NewBookRepositoryMemory(), reg)
+	ctx := context.Background()
+
+	book, err := repo.CreateBook(ctx, &domain.Book{Title: "Dune", Author: "Frank Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD")})
+	assert.NoError(t, err)
+	_, err = repo.GetBook(ctx, book.ID)
+	assert.NoError(t, err)
+	_, err = repo.GetBook(ctx, 99)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	err = repo.DeleteBook(ctx, book.ID, book.Version+1)
+	assert.ErrorIs(t, err, domain.ErrStale)
+
+	expected := `
+# HELP books_repository_errors_total Book repository calls that returned an error, by kind.
+# TYPE books_repository_errors_total counter
+books_repository_errors_total{kind="not_found",method="GetBook"} 1
+books_repository_errors_total{kind="stale",method="DeleteBook"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "books_repository_errors_total"))
+	assert.Equal(t, 3, testutil.CollectAndCount(reg, "books_repository_call_duration_seconds"))
+}
@@ -0,0 +1,136 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type BookRepositoryPostgres struct {
+	DB *sql.DB
+}
+
+func NewBookRepositoryPostgres(db *sql.DB) *BookRepositoryPostgres {
+	return &BookRepositoryPostgres{DB: db}
+}
+
+func (r *BookRepositoryPostgres) GetAll(ctx context.Context, q domain.BookQuery) ([]domain.Book, error) {
+	query, args := buildBookQuery(postgresDialect, q)
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+
+	var books []domain.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, bookError(err, 0)
+		}
+		books = append(books, book)
+	}
+	return books, bookError(rows.Err(), 0)
+}
+
+func (r *BookRepositoryPostgres) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = $1 AND deleted_at IS NULL`, ID))
+	if err != nil {
+		return domain.Book{}, bookError(err, ID)
+	}
+	return book, nil
+}
+
+func (r *BookRepositoryPostgres) CreateBook(ctx context.Context, newBook *domain.Book) (*domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO books (title, author, genre, price, currency, stock, isbn) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+bookColumns,
+		newBook.Title, newBook.Author, newBook.Genre, newBook.Price, newBook.Price.Currency, newBook.Stock, newBook.ISBN))
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositoryPostgres) UpdateBook(ctx context.Context, updateBook *domain.Book, ID int) (*domain.Book, error) {
+	return r.PatchBook(ctx, replacement(updateBook), ID)
+}
+
+func (r *BookRepositoryPostgres) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	if patch.IsEmpty() {
+		return currentBook(ctx, r, ID, patch.Version)
+	}
+
+	b := &queryBuilder{dialect: postgresDialect}
+	set := append(patchAssignments(b, patch), "updated_at=now()", "version=version+1")
+	query := `UPDATE books SET ` + strings.Join(set, ", ") + versionCondition(b, ID, patch.Version) + ` RETURNING ` + bookColumns
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, query, b.args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, missedWrite(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL`, ID), ID)
+	}
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositoryPostgres) DeleteBook(ctx context.Context, ID int, version int) error {
+	b := &queryBuilder{dialect: postgresDialect}
+	query := `UPDATE books SET deleted_at=now(), version=version+1` + versionCondition(b, ID, version)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return bookError(err, ID)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return bookError(err, ID)
+	}
+
+	if rowsAffected == 0 {
+		return missedWrite(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL`, ID), ID)
+	}
+	return nil
+}
+
+func (r *BookRepositoryPostgres) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `UPDATE books SET deleted_at=NULL, version=version+1 WHERE id=$1 AND deleted_at IS NOT NULL RETURNING `+bookColumns, ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &domain.NotFoundError{Resource: "deleted book", ID: ID}
+	}
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositoryPostgres) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	return purgeDeleted(ctx, r.DB, postgresDialect, before)
+}
+
+func (r *BookRepositoryPostgres) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	tsquery := strings.Join(terms, ":* & ") + ":*"
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT `+bookColumns+`, ts_rank(search, q) AS score FROM books, to_tsquery('simple', $1) q WHERE search @@ q AND deleted_at IS NULL ORDER BY score DESC, id LIMIT $2`, tsquery, limit)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+
+	var results []domain.SearchResult
+	for rows.Next() {
+		var result domain.SearchResult
+		if result.Book, err = scanBook(rows, &result.Score); err != nil {
+			return nil, bookError(err, 0)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	return withSnippets(results, terms), nil
+}
@@ -0,0 +1,176 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookRepositoryPostgres_CreateBook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	type testCase struct {
+		name        string
+		input       *domain.Book
+		expected    *domain.Book
+		mockSetup   func()
+		shouldError bool
+	}
+	tests := []testCase{
+		{
+			name: "success - create book",
+			input: &domain.Book{
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
+			},
+			expected: &domain.Book{
+				ID: 7, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: now, UpdatedAt: now, Version: 1,
+			},
+			mockSetup: func() {
+				row := sqlmock.NewRows(bookColumnNames).AddRow(7, "Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", now, now, 1, nil)
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO books (title, author, genre, price, currency, stock, isbn) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING")).
+					WithArgs("Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "").WillReturnRows(row)
+			},
+		},
+		{
+			name: "not success - create book",
+			input: &domain.Book{
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
+			},
+			mockSetup: func() {
+				mock.ExpectQuery("INSERT INTO books").WillReturnError(fmt.Errorf("Ohh no! Error!"))
+			},
+			shouldError: true,
+		},
+	}
+	repo := infrastucture.NewBookRepositoryPostgres(db)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+			result, err := repo.CreateBook(context.Background(), tc.input)
+			if tc.shouldError {
+				assert.Error(t, err)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, result)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBookRepositoryPostgres_UpdateBook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	type testCase struct {
+		name      string
+		ID        int
+		input     *domain.Book
+		expected  *domain.Book
+		mockSetup func()
+		errIs     error
+	}
+	tests := []testCase{
+		{
+			name: "Successful book update",
+			ID:   1,
+			input: &domain.Book{
+				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 3,
+			},
+			expected: &domain.Book{
+				ID: 1, Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: created, UpdatedAt: updated, Version: 4,
+			},
+			mockSetup: func() {
+				row := sqlmock.NewRows(bookColumnNames).AddRow(1, "Updated Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", created, updated, 4, nil)
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE books SET title=$1, author=$2, genre=$3, price=$4, currency=$5, stock=$6, isbn=$7, updated_at=now(), version=version+1 WHERE id=$8 AND deleted_at IS NULL AND version=$9 RETURNING")).
+					WithArgs("Updated Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", 1, 3).WillReturnRows(row)
+			},
+		},
+		{
+			name:  "Missing book",
+			ID:    2,
+			input: &domain.Book{},
+			mockSetup: func() {
+				mock.ExpectQuery("UPDATE books").WillReturnRows(sqlmock.NewRows(bookColumnNames))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL")).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+			},
+			errIs: domain.ErrNotFound,
+		},
+		{
+			name:  "Stale version",
+			ID:    1,
+			input: &domain.Book{Version: 2},
+			mockSetup: func() {
+				mock.ExpectQuery("UPDATE books").WillReturnRows(sqlmock.NewRows(bookColumnNames))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL")).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+			},
+			errIs: domain.ErrStale,
+		},
+	}
+	repo := infrastucture.NewBookRepositoryPostgres(db)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+			result, err := repo.UpdateBook(context.Background(), tc.input, tc.ID)
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+				assert.Nil(t, result)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, result)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// TestBookRepositoryPostgres runs the shared contract against a real server
+// when BOOKS_TEST_POSTGRES_DSN points at an empty database.
+func TestBookRepositoryPostgres(t *testing.T) {
+	dsn := os.Getenv("BOOKS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("BOOKS_TEST_POSTGRES_DSN not set")
+	}
+
+	testBookRepositoryContract(t, func(t *testing.T) domain.BookRepository {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatalf("Error opening postgres: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		m, err := migrations.NewMigrator(db, "postgres")
+		if err != nil {
+			t.Fatalf("Error loading migrations: %v", err)
+		}
+		if _, err := m.Down(len(m.Migrations())); err != nil {
+			t.Fatalf("Error resetting schema: %v", err)
+		}
+		if _, err := m.Up(); err != nil {
+			t.Fatalf("Error applying migrations: %v", err)
+		}
+		return infrastucture.NewBookRepositoryPostgres(db)
+	})
+}
@@ -0,0 +1,139 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type BookRepositorySQLite struct {
+	DB *sql.DB
+}
+
+func NewBookRepositorySQLite(db *sql.DB) *BookRepositorySQLite {
+	return &BookRepositorySQLite{DB: db}
+}
+
+func (r *BookRepositorySQLite) GetAll(ctx context.Context, q domain.BookQuery) ([]domain.Book, error) {
+	query, args := buildBookQuery(sqliteDialect, q)
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+
+	var books []domain.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, bookError(err, 0)
+		}
+		books = append(books, book)
+	}
+	return books, bookError(rows.Err(), 0)
+}
+
+func (r *BookRepositorySQLite) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = ? AND deleted_at IS NULL`, ID))
+	if err != nil {
+		return domain.Book{}, bookError(err, ID)
+	}
+	return book, nil
+}
+
+func (r *BookRepositorySQLite) CreateBook(ctx context.Context, newBook *domain.Book) (*domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO books (title, author, genre, price, currency, stock, isbn) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING `+bookColumns,
+		newBook.Title, newBook.Author, newBook.Genre, newBook.Price, newBook.Price.Currency, newBook.Stock, newBook.ISBN))
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositorySQLite) UpdateBook(ctx context.Context, updateBook *domain.Book, ID int) (*domain.Book, error) {
+	return r.PatchBook(ctx, replacement(updateBook), ID)
+}
+
+func (r *BookRepositorySQLite) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	if patch.IsEmpty() {
+		return currentBook(ctx, r, ID, patch.Version)
+	}
+
+	b := &queryBuilder{dialect: sqliteDialect}
+	set := append(patchAssignments(b, patch), "updated_at=CURRENT_TIMESTAMP", "version=version+1")
+	query := `UPDATE books SET ` + strings.Join(set, ", ") + versionCondition(b, ID, patch.Version) + ` RETURNING ` + bookColumns
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, query, b.args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, missedWrite(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = ? AND deleted_at IS NULL`, ID), ID)
+	}
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositorySQLite) DeleteBook(ctx context.Context, ID int, version int) error {
+	b := &queryBuilder{dialect: sqliteDialect}
+	query := `UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version+1` + versionCondition(b, ID, version)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return bookError(err, ID)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return bookError(err, ID)
+	}
+
+	if rowsAffected == 0 {
+		return missedWrite(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT 1 FROM books WHERE id = ? AND deleted_at IS NULL`, ID), ID)
+	}
+	return nil
+}
+
+// RestoreBook takes book ID back out of the trash.
+func (r *BookRepositorySQLite) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	book, err := scanBook(conn(ctx, r.DB).QueryRowContext(ctx, `UPDATE books SET deleted_at=NULL, version=version+1 WHERE id=? AND deleted_at IS NOT NULL RETURNING `+bookColumns, ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &domain.NotFoundError{Resource: "deleted book", ID: ID}
+	}
+	if err != nil {
+		return nil, bookError(err, ID)
+	}
+	return &book, nil
+}
+
+func (r *BookRepositorySQLite) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	return purgeDeleted(ctx, r.DB, sqliteDialect, before)
+}
+
+// Search matches through the books_fts FTS4 index and ranks in Go, since
+// FTS4 has no built-in relevance function.
+func (r *BookRepositorySQLite) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	match := strings.Join(terms, "* ") + "*"
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id IN (SELECT docid FROM books_fts WHERE books_fts MATCH ?) AND deleted_at IS NULL`, match)
+	if err != nil {
+		return nil, bookError(err, 0)
+	}
+	defer rows.Close()
+
+	var books []domain.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, bookError(err, 0)
+		}
+		books = append(books, book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, bookError(err, 0)
+	}
+	return rankBooks(books, terms, limit), nil
+}
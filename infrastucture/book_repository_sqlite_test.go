@@ -0,0 +1,94 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/migrations"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Error applying migrations: %v", err)
+	}
+	return db
+}
+
+func TestBookRepositorySQLite(t *testing.T) {
+	testBookRepositoryContract(t, func(t *testing.T) domain.BookRepository {
+		return infrastucture.NewBookRepositorySQLite(newSQLiteDB(t))
+	})
+}
+
+func TestSQLTransactor_SQLiteConcurrentWriters(t *testing.T) {
+	// A file shared by several connections, unlike :memory:.
+	db, err := sql.Open("sqlite3", infrastucture.SQLiteDSN("file:"+filepath.Join(t.TempDir(), "books.db")))
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Error applying migrations: %v", err)
+	}
+
+	repo, tx := infrastucture.NewBookRepositorySQLite(db), infrastucture.NewSQLTransactor(db)
+	_, err = repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title", Author: "Test Author", Price: domain.NewMoney(1000, "USD")})
+	assert.NoError(t, err)
+
+	// Every writer reads the book before it writes, as audited writes do.
+	errs := make([]error, 40)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+				book, err := repo.GetBook(ctx, 1)
+				if err != nil {
+					return err
+				}
+				stock := book.Stock + 1
+				_, err = repo.PatchBook(ctx, domain.BookPatch{Stock: &stock, Version: book.Version}, 1)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	book, err := repo.GetBook(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, book.Stock)
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	assert.Equal(t, "books.db?_txlock=immediate", infrastucture.SQLiteDSN("books.db"))
+	assert.Equal(t, "file:books.db?cache=shared&_txlock=immediate", infrastucture.SQLiteDSN("file:books.db?cache=shared"))
+	assert.Equal(t, "books.db?_txlock=exclusive", infrastucture.SQLiteDSN("books.db?_txlock=exclusive"))
+}
@@ -3,15 +3,19 @@ package infrastucture_test
 import (
 	"book-apis/domain"
 	"book-apis/infrastucture"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 )
 
+var bookColumnNames = []string{"id", "title", "author", "genre", "price", "currency", "stock", "isbn", "created_at", "updated_at", "version", "deleted_at"}
+
 func TestBookRepositoryDB_GetAll(t *testing.T) {
 	type testCase struct {
 		name        string
@@ -28,17 +32,18 @@ func TestBookRepositoryDB_GetAll(t *testing.T) {
 	defer db.Close()
 
 	repo := infrastucture.NewBookRepositoryDB(db)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
 
 	tests := []testCase{
 		{
 			name: "success - fetch all books",
 			expected: []domain.Book{
-				{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10},
-				{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: "150", Stock: 20},
+				{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: now, UpdatedAt: now, Version: 1},
+				{ID: 2, Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20, CreatedAt: now, UpdatedAt: now, Version: 1},
 			},
 			mockSetup: func() {
-				rows := sqlmock.NewRows([]string{"title", "author", "genre", "price", "stock"}).AddRow("Test Title 1", "Test Author 1", "Horror", "100", 10).AddRow("Test Title 2", "Test Author 2", "Adventure", "150", 20)
-				mock.ExpectQuery("SELECT title, author, genre, price, stock FROM books").WillReturnRows(rows)
+				rows := sqlmock.NewRows(bookColumnNames).AddRow(1, "Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", now, now, 1, nil).AddRow(2, "Test Title 2", "Test Author 2", "Adventure", 15000, "USD", 20, "", now, now, 1, nil)
+				mock.ExpectQuery("SELECT id, title, author, genre, price, currency, stock, isbn, created_at, updated_at, version, deleted_at FROM books WHERE deleted_at IS NULL").WillReturnRows(rows)
 			},
 			shouldError: false,
 		},
@@ -46,7 +51,7 @@ func TestBookRepositoryDB_GetAll(t *testing.T) {
 			name:     "failure - query execution fails",
 			expected: nil,
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT title, author, genre, price, stock FROM books").WillReturnError(fmt.Errorf("Some DB error"))
+				mock.ExpectQuery("SELECT id, title, author, genre, price, currency, stock, isbn, created_at, updated_at, version, deleted_at FROM books WHERE deleted_at IS NULL").WillReturnError(fmt.Errorf("Some DB error"))
 			},
 			shouldError: true,
 		},
@@ -56,7 +61,7 @@ func TestBookRepositoryDB_GetAll(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			books, err := repo.GetAll()
+			books, err := repo.GetAll(context.Background(), domain.BookQuery{})
 
 			if tc.shouldError {
 				assert.Error(t, err)
@@ -89,17 +94,18 @@ func TestBookRepositoryDB_GetOneBook(t *testing.T) {
 	defer db.Close()
 
 	repo := infrastucture.NewBookRepositoryDB(db)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
 
 	tests := []testCase{
 		{
 			name: "success - fetch one book",
 			ID:   1,
 			expected: domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: now, UpdatedAt: now, Version: 1,
 			},
 			mockSetup: func() {
-				row := sqlmock.NewRows([]string{"title", "author", "genre", "price", "stock"}).AddRow("Test Title 1", "Test Author 1", "Horror", "100", 10)
-				mock.ExpectQuery("SELECT title, author, genre, price, stock FROM books WHERE id = ?").WithArgs(1).WillReturnRows(row)
+				row := sqlmock.NewRows(bookColumnNames).AddRow(1, "Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", now, now, 1, nil)
+				mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(1).WillReturnRows(row)
 			},
 			shouldError: false,
 		},
@@ -108,7 +114,7 @@ func TestBookRepositoryDB_GetOneBook(t *testing.T) {
 			ID:       2,
 			expected: domain.Book{},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT title, author, genre, price, stock FROM books WHERE id = ?").WithArgs(2).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(2).WillReturnError(sql.ErrNoRows)
 			},
 			shouldError: true,
 		},
@@ -117,10 +123,10 @@ func TestBookRepositoryDB_GetOneBook(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
-			book, err := repo.GetBook(tc.ID)
+			book, err := repo.GetBook(context.Background(), tc.ID)
 			if tc.shouldError {
-				assert.Error(t, err)
-				assert.Equal(t, tc.expected, domain.Book{})
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+				assert.Equal(t, tc.expected, book)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tc.expected, book)
@@ -138,6 +144,7 @@ func TestBookRepositoryDB_CreateBook(t *testing.T) {
 	}
 
 	defer db.Close()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
 	type testCase struct {
 		name        string
 		input       *domain.Book
@@ -150,24 +157,42 @@ func TestBookRepositoryDB_CreateBook(t *testing.T) {
 		{
 			name: "success - create book",
 			input: &domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			expected: &domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				ID: 5, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: now, UpdatedAt: now, Version: 1,
 			},
 			mockSetup: func() {
-				row := sqlmock.NewRows([]string{"title", "author", "genre", "price", "stock"}).AddRow("Test Title 1", "Test Author 1", "Horror", "100", 10)
-				mock.ExpectQuery("INSERT INTO books").WithArgs("Test Title 1", "Test Author 1", "Horror", "100", 10).WillReturnRows(row)
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO books").WithArgs("Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "").WillReturnResult(sqlmock.NewResult(5, 1))
+				row := sqlmock.NewRows(bookColumnNames).AddRow(5, "Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", now, now, 1, nil)
+				mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(5).WillReturnRows(row)
+				mock.ExpectCommit()
 			},
 			shouldError: false,
 		},
 		{
 			name: "not success - create book",
 			input: &domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
+			},
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO books").WillReturnError(fmt.Errorf("Ohh no! Error!"))
+				mock.ExpectRollback()
+			},
+			shouldError: true,
+		},
+		{
+			name: "not success - re-select fails",
+			input: &domain.Book{
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("INSERT INTO books").WithArgs().WillReturnError(fmt.Errorf("Ohh no! Error!"))
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO books").WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(5).WillReturnError(fmt.Errorf("Ohh no! Error!"))
+				mock.ExpectRollback()
 			},
 			shouldError: true,
 		},
@@ -176,7 +201,7 @@ func TestBookRepositoryDB_CreateBook(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
-			result, err := repo.CreateBook(tc.input)
+			result, err := repo.CreateBook(context.Background(), tc.input)
 			if tc.shouldError {
 				assert.Error(t, err)
 				assert.Nil(t, result)
@@ -196,6 +221,8 @@ func TestBookRepositoryDB_UpdateBook(t *testing.T) {
 		t.Fatalf("Error initializing sqlmock: %v", err)
 	}
 	defer db.Close()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := created.Add(time.Hour)
 	type testCase struct {
 		name        string
 		ID          int
@@ -209,14 +236,17 @@ func TestBookRepositoryDB_UpdateBook(t *testing.T) {
 			name: "Successful book update",
 			ID:   1,
 			input: &domain.Book{
-				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			expected: &domain.Book{
-				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				ID: 1, Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: created, UpdatedAt: updated, Version: 1,
 			},
 			mockSetup: func() {
-				row := sqlmock.NewRows([]string{"title", "author", "genre", "price", "stock"}).AddRow("Updated Test Title 1", "Test Author 1", "Horror", "100", 10)
-				mock.ExpectQuery("UPDATE books").WithArgs("Updated Test Title 1", "Test Author 1", "Horror", "100", 10, 1).WillReturnRows(row)
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE books").WithArgs("Updated Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				row := sqlmock.NewRows(bookColumnNames).AddRow(1, "Updated Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", created, updated, 1, nil)
+				mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(1).WillReturnRows(row)
+				mock.ExpectCommit()
 			},
 			shouldError: false,
 		},
@@ -226,7 +256,22 @@ func TestBookRepositoryDB_UpdateBook(t *testing.T) {
 			input:    &domain.Book{},
 			expected: nil,
 			mockSetup: func() {
-				mock.ExpectQuery("UPDATE books").WithArgs().WillReturnError(fmt.Errorf("Oh no error!!"))
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE books").WillReturnError(fmt.Errorf("Oh no error!!"))
+				mock.ExpectRollback()
+			},
+			shouldError: true,
+		},
+		{
+			name:     "Missing book",
+			ID:       3,
+			input:    &domain.Book{},
+			expected: nil,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE books").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT 1 FROM books WHERE id = \\? AND deleted_at IS NULL").WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+				mock.ExpectRollback()
 			},
 			shouldError: true,
 		},
@@ -235,7 +280,7 @@ func TestBookRepositoryDB_UpdateBook(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
-			result, err := repo.UpdateBook(tc.input, tc.ID)
+			result, err := repo.UpdateBook(context.Background(), tc.input, tc.ID)
 			if tc.shouldError {
 				assert.Error(t, err)
 				assert.Nil(t, result)
@@ -243,10 +288,33 @@ func TestBookRepositoryDB_UpdateBook(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, tc.expected, result)
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
+func TestBookRepositoryDB_PatchBook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stock, price := 4, domain.NewMoney(1200, "EUR")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE books SET price=\\?, currency=\\?, stock=\\?, version=version\\+1 WHERE id=\\? AND deleted_at IS NULL").WithArgs(1200, "EUR", 4, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	row := sqlmock.NewRows(bookColumnNames).AddRow(1, "Test Title 1", "Test Author 1", "Horror", 1200, "EUR", 4, "", now, now, 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(1).WillReturnRows(row)
+	mock.ExpectCommit()
+
+	repo := infrastucture.NewBookRepositoryDB(db)
+	result, err := repo.PatchBook(context.Background(), domain.BookPatch{Price: &price, Stock: &stock}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: price, Stock: 4, CreatedAt: now, UpdatedAt: now, Version: 1}, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -256,6 +324,7 @@ func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 	type testCase struct {
 		name        string
 		ID          int
+		version     int
 		expected    string
 		mockSetup   func()
 		shouldError bool
@@ -266,16 +335,28 @@ func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 			ID:       1,
 			expected: "",
 			mockSetup: func() {
-				mock.ExpectExec("DELETE FROM books WHERE id=?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version\\+1 WHERE id=\\? AND deleted_at IS NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			shouldError: false,
 		},
 		{
 			name:     "No row deletion",
 			ID:       2,
-			expected: "no rows were deleted",
+			expected: "book 2 not found",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version\\+1 WHERE id=\\? AND deleted_at IS NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT 1 FROM books WHERE id = \\? AND deleted_at IS NULL").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+			},
+			shouldError: true,
+		},
+		{
+			name:     "Stale version",
+			ID:       1,
+			version:  2,
+			expected: "book 1 has been modified",
 			mockSetup: func() {
-				mock.ExpectExec("DELETE FROM books WHERE id=?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version\\+1 WHERE id=\\? AND deleted_at IS NULL AND version=\\?").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT 1 FROM books WHERE id = \\? AND deleted_at IS NULL").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
 			},
 			shouldError: true,
 		},
@@ -284,7 +365,7 @@ func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 			ID:       1,
 			expected: "Oh no error",
 			mockSetup: func() {
-				mock.ExpectExec("DELETE FROM books WHERE id=?").WithArgs(1).WillReturnError(errors.New("Oh no error"))
+				mock.ExpectExec("UPDATE books SET deleted_at=CURRENT_TIMESTAMP, version=version\\+1 WHERE id=\\? AND deleted_at IS NULL").WithArgs(1).WillReturnError(errors.New("Oh no error"))
 			},
 			shouldError: true,
 		},
@@ -294,7 +375,7 @@ func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.mockSetup()
 
-			err := repo.DeleteBook(tc.ID)
+			err := repo.DeleteBook(context.Background(), tc.ID, tc.version)
 
 			if tc.shouldError {
 				assert.Error(t, err)
@@ -311,3 +392,75 @@ func TestBookRepositoryDB_DeleteBook(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestBookRepositoryDB_RestoreBook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE books SET deleted_at=NULL, version=version\\+1 WHERE id=\\? AND deleted_at IS NOT NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	row := sqlmock.NewRows(bookColumnNames).AddRow(1, "Test Title 1", "Test Author 1", "Horror", 10000, "USD", 10, "", now, now, 3, nil)
+	mock.ExpectQuery("SELECT (.+) FROM books WHERE id = ?").WithArgs(1).WillReturnRows(row)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE books SET deleted_at=NULL").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	repo := infrastucture.NewBookRepositoryDB(db)
+	book, err := repo.RestoreBook(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Book{ID: 1, Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, CreatedAt: now, UpdatedAt: now, Version: 3}, book)
+
+	_, err = repo.RestoreBook(context.Background(), 2)
+	assert.EqualError(t, err, "deleted book 2 not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookRepositoryDB_PurgeDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	before := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM books WHERE deleted_at IS NOT NULL AND deleted_at < \\? ORDER BY id FOR UPDATE").WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(5).AddRow(8))
+	mock.ExpectExec("DELETE FROM books WHERE deleted_at IS NOT NULL AND deleted_at < \\?").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	repo := infrastucture.NewBookRepositoryDB(db)
+	IDs, err := repo.PurgeDeleted(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 5, 8}, IDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookRepositoryDB_Search(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := sqlmock.NewRows(append(bookColumnNames, "score")).
+		AddRow(1, "The Shining", "Stephen King", "Horror", 900, "USD", 1, "", now, now, 1, nil, 1.5)
+	mock.ExpectQuery("SELECT (.+) MATCH\\(title, author, genre\\) AGAINST \\(\\? IN BOOLEAN MODE\\) AS score FROM books").
+		WithArgs("+stephen* +kin*", "+stephen* +kin*", 5).WillReturnRows(rows)
+
+	repo := infrastucture.NewBookRepositoryDB(db)
+	results, err := repo.Search(context.Background(), "Stephen kin+", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.SearchResult{{
+		Book:    domain.Book{ID: 1, Title: "The Shining", Author: "Stephen King", Genre: "Horror", Price: domain.NewMoney(900, "USD"), Stock: 1, CreatedAt: now, UpdatedAt: now, Version: 1},
+		Score:   1.5,
+		Snippet: "The Shining — <mark>Stephen</mark> <mark>King</mark> (Horror)",
+	}}, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BookRepositoryTracing decorates another BookRepository with a span per
+// method. The SQL statements a method runs show up as its children when
+// the database handle is opened through otelsql.
+type BookRepositoryTracing struct {
+	repo   domain.BookRepository
+	tracer trace.Tracer
+}
+
+func NewBookRepositoryTracing(repo domain.BookRepository, tp trace.TracerProvider) *BookRepositoryTracing {
+	return &BookRepositoryTracing{repo: repo, tracer: tp.Tracer("book-apis/infrastucture")}
+}
+
+func (t *BookRepositoryTracing) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "BookRepository."+method, trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+func (t *BookRepositoryTracing) end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *BookRepositoryTracing) GetAll(ctx context.Context, query domain.BookQuery) ([]domain.Book, error) {
+	ctx, span := t.start(ctx, "GetAll")
+	books, err := t.repo.GetAll(ctx, query)
+	t.end(span, err)
+	return books, err
+}
+
+func (t *BookRepositoryTracing) GetBook(ctx context.Context, ID int) (domain.Book, error) {
+	ctx, span := t.start(ctx, "GetBook")
+	book, err := t.repo.GetBook(ctx, ID)
+	t.end(span, err)
+	return book, err
+}
+
+func (t *BookRepositoryTracing) CreateBook(ctx context.Context, book *domain.Book) (*domain.Book, error) {
+	ctx, span := t.start(ctx, "CreateBook")
+	created, err := t.repo.CreateBook(ctx, book)
+	t.end(span, err)
+	return created, err
+}
+
+func (t *BookRepositoryTracing) UpdateBook(ctx context.Context, book *domain.Book, ID int) (*domain.Book, error) {
+	ctx, span := t.start(ctx, "UpdateBook")
+	updated, err := t.repo.UpdateBook(ctx, book, ID)
+	t.end(span, err)
+	return updated, err
+}
+
+func (t *BookRepositoryTracing) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	ctx, span := t.start(ctx, "PatchBook")
+	book, err := t.repo.PatchBook(ctx, patch, ID)
+	t.end(span, err)
+	return book, err
+}
+
+func (t *BookRepositoryTracing) DeleteBook(ctx context.Context, ID int, version int) error {
+	ctx, span := t.start(ctx, "DeleteBook")
+	err := t.repo.DeleteBook(ctx, ID, version)
+	t.end(span, err)
+	return err
+}
+
+func (t *BookRepositoryTracing) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	ctx, span := t.start(ctx, "Search")
+	results, err := t.repo.Search(ctx, query, limit)
+	t.end(span, err)
+	return results, err
+}
+
+func (t *BookRepositoryTracing) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	ctx, span := t.start(ctx, "RestoreBook")
+	book, err := t.repo.RestoreBook(ctx, ID)
+	t.end(span, err)
+	return book, err
+}
+
+func (t *BookRepositoryTracing) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	ctx, span := t.start(ctx, "PurgeDeleted")
+	IDs, err := t.repo.PurgeDeleted(ctx, before)
+	t.end(span, err)
+	return IDs, err
+}
@@ -0,0 +1,127 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const maxSearchTerms = 8
+
+// searchTerms lower-cases q and splits it into letter/digit runs, dropping
+// any query syntax so terms are safe to embed in engine match expressions.
+func searchTerms(q string) []string {
+	terms := words(q)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !isWordRune(r) })
+}
+
+// scoreBook ranks book for terms, which must all prefix-match some word.
+// Title matches weigh most, then author, then genre; whole-word matches
+// count double. It returns 0 when a term matches nothing.
+func scoreBook(book *domain.Book, terms []string) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{words(book.Title), 3},
+		{words(book.Author), 2},
+		{words(book.Genre), 1},
+	}
+
+	var score float64
+	for _, term := range terms {
+		var termScore float64
+		for _, f := range fields {
+			for _, w := range f.words {
+				switch {
+				case w == term:
+					termScore += 2 * f.weight
+				case strings.HasPrefix(w, term):
+					termScore += f.weight
+				}
+			}
+		}
+		if termScore == 0 {
+			return 0
+		}
+		score += termScore
+	}
+	return score
+}
+
+// rankBooks scores books in Go for engines without built-in ranking and
+// returns the best limit matches.
+func rankBooks(books []domain.Book, terms []string, limit int) []domain.SearchResult {
+	var results []domain.SearchResult
+	for i := range books {
+		if score := scoreBook(&books[i], terms); score > 0 {
+			results = append(results, domain.SearchResult{Book: books[i], Score: score})
+		}
+	}
+	slices.SortStableFunc(results, func(a, b domain.SearchResult) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return a.Book.ID - b.Book.ID
+		}
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return withSnippets(results, terms)
+}
+
+func withSnippets(results []domain.SearchResult, terms []string) []domain.SearchResult {
+	for i := range results {
+		results[i].Snippet = snippet(&results[i].Book, terms)
+	}
+	return results
+}
+
+// snippet renders "Title — Author (Genre)" with every word that starts with
+// one of terms wrapped in <mark>.
+func snippet(book *domain.Book, terms []string) string {
+	text := book.Title + " — " + book.Author
+	if book.Genre != "" {
+		text += " (" + book.Genre + ")"
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		lower := strings.ToLower(word)
+		if slices.ContainsFunc(terms, func(t string) bool { return strings.HasPrefix(lower, t) }) {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,29 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"stephen", "king"}, searchTerms("  Stephen KING "))
+	assert.Equal(t, []string{"herb", "or", "drop"}, searchTerms(`herb* OR "drop"`))
+	assert.Equal(t, []string{"café", "2001"}, searchTerms("Café: 2001!"))
+	assert.Len(t, searchTerms("a b c d e f g h i j"), maxSearchTerms)
+	assert.Empty(t, searchTerms("*** --"))
+}
+
+func TestScoreBook(t *testing.T) {
+	book := &domain.Book{Title: "The Shining", Author: "Stephen King", Genre: "Horror"}
+	assert.Zero(t, scoreBook(book, []string{"king", "dune"}))
+	assert.Greater(t, scoreBook(book, []string{"shining"}), scoreBook(book, []string{"king"}))
+	assert.Greater(t, scoreBook(book, []string{"king"}), scoreBook(book, []string{"kin"}))
+	assert.Greater(t, scoreBook(book, []string{"kin"}), scoreBook(book, []string{"hor"}))
+}
+
+func TestSnippet(t *testing.T) {
+	book := &domain.Book{Title: "Tom & Jerry <3", Author: "Hanna", Genre: ""}
+	assert.Equal(t, "<mark>Tom</mark> &amp; Jerry &lt;3 — Hanna", snippet(book, []string{"to"}))
+}
@@ -0,0 +1,83 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// missedWrite explains a conditional write on book ID that matched no row,
+// given a query selecting the live book: either it is gone or it has moved
+// on to another version.
+func missedWrite(row *sql.Row, ID int) error {
+	var exists int
+	if err := row.Scan(&exists); err != nil {
+		return bookError(err, ID)
+	}
+	return &domain.StaleError{Resource: "book", ID: ID}
+}
+
+// bookError translates driver errors from a statement on book ID into the
+// domain error types. Errors it does not recognise are returned unchanged.
+func bookError(err error, ID int) error {
+	return dbError(err, "book", ID)
+}
+
+// dbError is bookError for any resource.
+func dbError(err error, resource string, ID int) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &domain.NotFoundError{Resource: resource, ID: ID}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &domain.TimeoutError{Err: err}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062:
+			return &domain.ConflictError{Message: resource + " already exists", Err: err}
+		case 1040, 1205, 1213:
+			return &domain.UnavailableError{Err: err}
+		}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return &domain.ConflictError{Message: resource + " already exists", Err: err}
+		case strings.HasPrefix(string(pqErr.Code), "08"), pqErr.Code == "53300", pqErr.Code == "57P01", pqErr.Code == "40001":
+			return &domain.UnavailableError{Err: err}
+		}
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique, sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+			return &domain.ConflictError{Message: resource + " already exists", Err: err}
+		case sqliteErr.Code == sqlite3.ErrBusy, sqliteErr.Code == sqlite3.ErrLocked:
+			return &domain.UnavailableError{Err: err}
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &netErr) {
+		return &domain.UnavailableError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookError(t *testing.T) {
+	type testCase struct {
+		name     string
+		input    error
+		expected error
+	}
+
+	tests := []testCase{
+		{name: "no rows", input: sql.ErrNoRows, expected: domain.ErrNotFound},
+		{name: "mysql duplicate key", input: &mysql.MySQLError{Number: 1062}, expected: domain.ErrConflict},
+		{name: "mysql too many connections", input: &mysql.MySQLError{Number: 1040}, expected: domain.ErrUnavailable},
+		{name: "postgres unique violation", input: &pq.Error{Code: "23505"}, expected: domain.ErrConflict},
+		{name: "postgres connection failure", input: &pq.Error{Code: "08006"}, expected: domain.ErrUnavailable},
+		{name: "sqlite unique constraint", input: sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, expected: domain.ErrConflict},
+		{name: "sqlite busy", input: sqlite3.Error{Code: sqlite3.ErrBusy}, expected: domain.ErrUnavailable},
+		{name: "deadline exceeded", input: fmt.Errorf("query: %w", context.DeadlineExceeded), expected: domain.ErrTimeout},
+		{name: "bad connection", input: fmt.Errorf("query: %w", driver.ErrBadConn), expected: domain.ErrUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, bookError(tc.input, 1), tc.expected)
+		})
+	}
+
+	other := errors.New("Some DB error")
+	assert.Equal(t, other, bookError(other, 1))
+	assert.NoError(t, bookError(nil, 1))
+}
@@ -0,0 +1,114 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyRepositoryDB keeps idempotency keys and the responses they
+// replay in the idempotency_keys table.
+type IdempotencyRepositoryDB struct {
+	DB      *sql.DB
+	dialect sqlDialect
+}
+
+func NewIdempotencyRepositoryDB(db *sql.DB) *IdempotencyRepositoryDB {
+	return &IdempotencyRepositoryDB{DB: db, dialect: mysqlDialect}
+}
+
+func NewIdempotencyRepositoryPostgres(db *sql.DB) *IdempotencyRepositoryDB {
+	return &IdempotencyRepositoryDB{DB: db, dialect: postgresDialect}
+}
+
+func NewIdempotencyRepositorySQLite(db *sql.DB) *IdempotencyRepositoryDB {
+	return &IdempotencyRepositoryDB{DB: db, dialect: sqliteDialect}
+}
+
+// CreateIdempotencyKey inserts the key with no response yet. A duplicate
+// is ignored rather than reported as an error, as PostgreSQL aborts the
+// transaction on one.
+func (r *IdempotencyRepositoryDB) CreateIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) (bool, error) {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `INSERT INTO idempotency_keys (owner, idem_key, fingerprint, header, body, expires_at) VALUES (` +
+		b.arg(record.Owner) + `, ` + b.arg(record.Key) + `, ` + b.arg(record.Fingerprint) + `, ` + b.arg("{}") + `, ` + b.arg([]byte{}) + `, ` + b.arg(r.dialect.timeArg(record.ExpiresAt)) + `)` +
+		r.dialect.ignoreConflict("owner")
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return false, dbError(err, "idempotency key", 0)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, dbError(err, "idempotency key", 0)
+	}
+	return n > 0, nil
+}
+
+func (r *IdempotencyRepositoryDB) GetIdempotencyKey(ctx context.Context, owner, key string) (domain.IdempotencyRecord, error) {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `SELECT owner, idem_key, fingerprint, status, header, body, created_at, expires_at FROM idempotency_keys WHERE owner = ` +
+		b.arg(owner) + ` AND idem_key = ` + b.arg(key)
+
+	var (
+		record domain.IdempotencyRecord
+		header string
+	)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, b.args...).Scan(
+		&record.Owner, &record.Key, &record.Fingerprint, &record.Response.Status, &header, &record.Response.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		return domain.IdempotencyRecord{}, dbError(err, "idempotency key", 0)
+	}
+	if err := json.Unmarshal([]byte(header), &record.Response.Header); err != nil {
+		return domain.IdempotencyRecord{}, err
+	}
+	return record, nil
+}
+
+func (r *IdempotencyRepositoryDB) SaveIdempotentResponse(ctx context.Context, record *domain.IdempotencyRecord) error {
+	header, err := json.Marshal(record.Response.Header)
+	if err != nil {
+		return err
+	}
+	body := record.Response.Body
+	if body == nil {
+		body = []byte{}
+	}
+	b := &queryBuilder{dialect: r.dialect}
+	query := `UPDATE idempotency_keys SET status = ` + b.arg(record.Response.Status) + `, header = ` + b.arg(string(header)) + `, body = ` + b.arg(body) +
+		`, expires_at = ` + b.arg(r.dialect.timeArg(record.ExpiresAt)) + ` WHERE owner = ` + b.arg(record.Owner) + ` AND idem_key = ` + b.arg(record.Key)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return dbError(err, "idempotency key", 0)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err, "idempotency key", 0)
+	}
+	if n == 0 {
+		return &domain.NotFoundError{Resource: "idempotency key"}
+	}
+	return nil
+}
+
+func (r *IdempotencyRepositoryDB) DeleteIdempotencyKey(ctx context.Context, owner, key string) error {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `DELETE FROM idempotency_keys WHERE owner = ` + b.arg(owner) + ` AND idem_key = ` + b.arg(key)
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	return dbError(err, "idempotency key", 0)
+}
+
+func (r *IdempotencyRepositoryDB) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	b := &queryBuilder{dialect: r.dialect}
+	query := `DELETE FROM idempotency_keys WHERE expires_at < ` + b.arg(r.dialect.timeArg(before))
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, b.args...)
+	if err != nil {
+		return 0, dbError(err, "idempotency key", 0)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, dbError(err, "idempotency key", 0)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,96 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+type idempotencyID struct {
+	owner, key string
+}
+
+type IdempotencyRepositoryMemory struct {
+	mu      sync.RWMutex
+	records map[idempotencyID]domain.IdempotencyRecord
+	now     func() time.Time
+}
+
+func cloneResponse(resp domain.IdempotentResponse) domain.IdempotentResponse {
+	resp.Header = maps.Clone(resp.Header)
+	resp.Body = slices.Clone(resp.Body)
+	return resp
+}
+
+func NewIdempotencyRepositoryMemory() *IdempotencyRepositoryMemory {
+	return &IdempotencyRepositoryMemory{
+		records: map[idempotencyID]domain.IdempotencyRecord{},
+		now:     func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (r *IdempotencyRepositoryMemory) CreateIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyID{record.Owner, record.Key}
+	if _, ok := r.records[id]; ok {
+		return false, nil
+	}
+	stored := *record
+	stored.Response = domain.IdempotentResponse{}
+	stored.CreatedAt = r.now()
+	r.records[id] = stored
+	return true, nil
+}
+
+func (r *IdempotencyRepositoryMemory) GetIdempotencyKey(ctx context.Context, owner, key string) (domain.IdempotencyRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[idempotencyID{owner, key}]
+	if !ok {
+		return domain.IdempotencyRecord{}, &domain.NotFoundError{Resource: "idempotency key"}
+	}
+	record.Response = cloneResponse(record.Response)
+	return record, nil
+}
+
+func (r *IdempotencyRepositoryMemory) SaveIdempotentResponse(ctx context.Context, record *domain.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyID{record.Owner, record.Key}
+	stored, ok := r.records[id]
+	if !ok {
+		return &domain.NotFoundError{Resource: "idempotency key"}
+	}
+	stored.Response = cloneResponse(record.Response)
+	stored.ExpiresAt = record.ExpiresAt
+	r.records[id] = stored
+	return nil
+}
+
+func (r *IdempotencyRepositoryMemory) DeleteIdempotencyKey(ctx context.Context, owner, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, idempotencyID{owner, key})
+	return nil
+}
+
+func (r *IdempotencyRepositoryMemory) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for id, record := range r.records {
+		if record.ExpiresAt.Before(before) {
+			delete(r.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
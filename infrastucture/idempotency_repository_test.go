@@ -0,0 +1,83 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testIdempotencyRepositoryContract(t *testing.T, repo domain.IdempotencyRepository) {
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	record := &domain.IdempotencyRecord{Owner: "api_key:1", Key: "key-1", Fingerprint: "fp-1", ExpiresAt: expires}
+
+	created, err := repo.CreateIdempotencyKey(ctx, record)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	created, err = repo.CreateIdempotencyKey(ctx, &domain.IdempotencyRecord{Owner: "api_key:1", Key: "key-1", Fingerprint: "fp-2", ExpiresAt: expires})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	created, err = repo.CreateIdempotencyKey(ctx, &domain.IdempotencyRecord{Owner: "api_key:2", Key: "key-1", Fingerprint: "fp-2", ExpiresAt: expires})
+	assert.NoError(t, err)
+	assert.True(t, created, "keys are scoped to their owner")
+
+	saved, err := repo.GetIdempotencyKey(ctx, "api_key:1", "key-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "fp-1", saved.Fingerprint)
+	assert.Zero(t, saved.Response.Status)
+	assert.WithinDuration(t, expires, saved.ExpiresAt, time.Second)
+	assert.False(t, saved.CreatedAt.IsZero())
+
+	record.Response = domain.IdempotentResponse{Status: 201, Header: map[string]string{"Location": "/books/1"}, Body: []byte(`{"id":1}`)}
+	record.ExpiresAt = expires.Add(time.Hour)
+	assert.NoError(t, repo.SaveIdempotentResponse(ctx, record))
+	saved, err = repo.GetIdempotencyKey(ctx, "api_key:1", "key-1")
+	assert.NoError(t, err)
+	assert.Equal(t, record.Response, saved.Response)
+	assert.WithinDuration(t, record.ExpiresAt, saved.ExpiresAt, time.Second)
+	assert.ErrorIs(t, repo.SaveIdempotentResponse(ctx, &domain.IdempotencyRecord{Owner: "api_key:3", Key: "key-1"}), domain.ErrNotFound)
+
+	assert.NoError(t, repo.DeleteIdempotencyKey(ctx, "api_key:1", "key-1"))
+	_, err = repo.GetIdempotencyKey(ctx, "api_key:1", "key-1")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	n, err := repo.PurgeIdempotencyKeys(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = repo.PurgeIdempotencyKeys(ctx, expires.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestIdempotencyRepositoryMemory(t *testing.T) {
+	testIdempotencyRepositoryContract(t, infrastucture.NewIdempotencyRepositoryMemory())
+}
+
+func TestIdempotencyRepositorySQLite(t *testing.T) {
+	testIdempotencyRepositoryContract(t, infrastucture.NewIdempotencyRepositorySQLite(newSQLiteDB(t)))
+}
+
+func TestIdempotencyRepositoryDB_CreateIdempotencyKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO idempotency_keys (owner, idem_key, fingerprint, header, body, expires_at) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE owner = owner")).
+		WithArgs("api_key:1", "key-1", "fp-1", "{}", []byte{}, expires).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	repo := infrastucture.NewIdempotencyRepositoryDB(db)
+	created, err := repo.CreateIdempotencyKey(context.Background(), &domain.IdempotencyRecord{Owner: "api_key:1", Key: "key-1", Fingerprint: "fp-1", ExpiresAt: expires})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
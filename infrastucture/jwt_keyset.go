@@ -0,0 +1,155 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwk is the subset of an RFC 7517 JSON Web Key the key set understands:
+// shared secrets ("oct") for HS256 and RSA public keys for RS256.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type verificationKey struct {
+	alg string
+	key any
+}
+
+// JWTKeySet verifies HS256 and RS256 tokens against keys read from a local
+// JWKS document. Tokens must carry sub and exp claims; iss and aud are
+// checked when the key set was given an issuer or audience.
+type JWTKeySet struct {
+	keys   map[string]verificationKey
+	parser *jwt.Parser
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Name  string   `json:"name,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// LoadJWTKeySet reads a JWKS document from path.
+func LoadJWTKeySet(path, issuer, audience string) (*JWTKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := NewJWTKeySet(data, issuer, audience)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func NewJWTKeySet(jwks []byte, issuer, audience string) (*JWTKeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Keys) == 0 {
+		return nil, errors.New("key set has no keys")
+	}
+
+	s := &JWTKeySet{keys: map[string]verificationKey{}}
+	for i, k := range doc.Keys {
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if _, ok := s.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, k.Kid)
+		}
+		s.keys[k.Kid] = key
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	s.parser = jwt.NewParser(opts...)
+	return s, nil
+}
+
+func (k jwk) verificationKey() (verificationKey, error) {
+	switch k.Kty {
+	case "oct":
+		if k.Alg != "" && k.Alg != "HS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %q for an oct key", k.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return verificationKey{}, errors.New("k must be a base64url secret of at least 32 bytes")
+		}
+		return verificationKey{alg: "HS256", key: secret}, nil
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return verificationKey{}, fmt.Errorf("unsupported alg %q for an RSA key", k.Alg)
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, errors.New("n and e must be base64url big-endian integers")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return verificationKey{alg: "RS256", key: pub}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+// keyFor picks the key named by the token's kid header, or the only key
+// when the set has one and the token names none, and insists the token is
+// signed with the algorithm that key is meant for.
+func (s *JWTKeySet) keyFor(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok && kid == "" && len(s.keys) == 1 {
+		for _, only := range s.keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("key %q is not for %s", kid, token.Method.Alg())
+	}
+	return key.key, nil
+}
+
+func (s *JWTKeySet) VerifyToken(ctx context.Context, token string) (domain.Principal, error) {
+	var claims tokenClaims
+	if _, err := s.parser.ParseWithClaims(token, &claims, s.keyFor); err != nil {
+		return domain.Principal{}, &domain.UnauthorizedError{Message: "invalid token: " + err.Error()}
+	}
+	if claims.Subject == "" {
+		return domain.Principal{}, &domain.UnauthorizedError{Message: "invalid token: sub claim is required"}
+	}
+	return domain.Principal{Subject: claims.Subject, Name: claims.Name, Method: "jwt", Roles: claims.Roles}, nil
+}
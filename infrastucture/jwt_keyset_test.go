@@ -0,0 +1,173 @@
+package infrastucture_test
+
+import (
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTKeySet_VerifyToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"oct","kid":"hs","alg":"HS256","k":%q},
+		{"kty":"RSA","kid":"rs","alg":"RS256","n":%q,"e":%q}
+	]}`, b64(secret), b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()))
+	keys, err := infrastucture.NewJWTKeySet([]byte(jwks), "https://id.example.com", "books")
+	if err != nil {
+		t.Fatalf("Error loading key set: %v", err)
+	}
+
+	claims := func(edit func(c jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":   "user-1",
+			"name":  "Ada",
+			"roles": []string{"clerk"},
+			"iss":   "https://id.example.com",
+			"aud":   "books",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, kid string, key any, c jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, c)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("Error signing token: %v", err)
+		}
+		return signed
+	}
+
+	type testCase struct {
+		name        string
+		token       string
+		expected    domain.Principal
+		shouldError bool
+	}
+	tests := []testCase{
+		{
+			name:     "success - HS256",
+			token:    sign(jwt.SigningMethodHS256, "hs", secret, claims(nil)),
+			expected: domain.Principal{Subject: "user-1", Name: "Ada", Method: "jwt", Roles: []string{"clerk"}},
+		},
+		{
+			name:     "success - RS256",
+			token:    sign(jwt.SigningMethodRS256, "rs", rsaKey, claims(nil)),
+			expected: domain.Principal{Subject: "user-1", Name: "Ada", Method: "jwt", Roles: []string{"clerk"}},
+		},
+		{
+			name:        "not success - HS256 token naming the RSA key",
+			token:       sign(jwt.SigningMethodHS256, "rs", secret, claims(nil)),
+			shouldError: true,
+		},
+		{
+			name:        "not success - signed by another key",
+			token:       sign(jwt.SigningMethodRS256, "rs", otherKey, claims(nil)),
+			shouldError: true,
+		},
+		{
+			name:        "not success - unknown kid",
+			token:       sign(jwt.SigningMethodHS256, "other", secret, claims(nil)),
+			shouldError: true,
+		},
+		{
+			name:        "not success - no kid with several keys",
+			token:       sign(jwt.SigningMethodHS256, "", secret, claims(nil)),
+			shouldError: true,
+		},
+		{
+			name:        "not success - unsupported alg",
+			token:       sign(jwt.SigningMethodHS512, "hs", secret, claims(nil)),
+			shouldError: true,
+		},
+		{
+			name:        "not success - expired",
+			token:       sign(jwt.SigningMethodHS256, "hs", secret, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })),
+			shouldError: true,
+		},
+		{
+			name:        "not success - no expiry",
+			token:       sign(jwt.SigningMethodHS256, "hs", secret, claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+			shouldError: true,
+		},
+		{
+			name:        "not success - wrong issuer",
+			token:       sign(jwt.SigningMethodHS256, "hs", secret, claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })),
+			shouldError: true,
+		},
+		{
+			name:        "not success - wrong audience",
+			token:       sign(jwt.SigningMethodHS256, "hs", secret, claims(func(c jwt.MapClaims) { c["aud"] = "orders" })),
+			shouldError: true,
+		},
+		{
+			name:        "not success - no subject",
+			token:       sign(jwt.SigningMethodHS256, "hs", secret, claims(func(c jwt.MapClaims) { delete(c, "sub") })),
+			shouldError: true,
+		},
+		{
+			name:        "not success - garbage",
+			token:       "not.a.token",
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			principal, err := keys.VerifyToken(context.Background(), tc.token)
+			if tc.shouldError {
+				assert.ErrorIs(t, err, domain.ErrUnauthorized)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, principal)
+			}
+		})
+	}
+}
+
+func TestNewJWTKeySet_Errors(t *testing.T) {
+	type testCase struct {
+		name   string
+		jwks   string
+		errMsg string
+	}
+	tests := []testCase{
+		{name: "not json", jwks: `keys`, errMsg: "invalid character"},
+		{name: "no keys", jwks: `{"keys":[]}`, errMsg: "key set has no keys"},
+		{name: "short secret", jwks: `{"keys":[{"kty":"oct","k":"c2hvcnQ"}]}`, errMsg: "at least 32 bytes"},
+		{name: "wrong alg", jwks: `{"keys":[{"kty":"RSA","alg":"HS256","n":"AQAB","e":"AQAB"}]}`, errMsg: `unsupported alg "HS256"`},
+		{name: "unsupported kty", jwks: `{"keys":[{"kty":"EC"}]}`, errMsg: `unsupported kty "EC"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := infrastucture.NewJWTKeySet([]byte(tc.jwks), "", "")
+			assert.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often buckets that have refilled are
+// dropped. A full bucket is the same as no bucket, so this only bounds
+// memory held for clients that went away.
+const rateLimitSweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   domain.RateLimit
+}
+
+// refill is the number of tokens b holds at now.
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*b.limit.Rate)
+}
+
+// RateLimitStoreMemory keeps token buckets in process, so each replica
+// enforces its own limits.
+type RateLimitStoreMemory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewRateLimitStoreMemory() *RateLimitStoreMemory {
+	return &RateLimitStoreMemory{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *RateLimitStoreMemory) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) >= rateLimitSweepInterval {
+		s.sweep(now)
+	}
+
+	burst := float64(limit.Burst)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens, b.updated = b.refill(now), now
+
+	result := domain.RateLimitResult{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / limit.Rate)
+	return result, nil
+}
+
+// sweep drops the buckets that have refilled by now.
+func (s *RateLimitStoreMemory) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if b.refill(now) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
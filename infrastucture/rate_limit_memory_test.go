@@ -0,0 +1,43 @@
+package infrastucture
+
+import (
+	"book-apis/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitStoreMemory(t *testing.T) {
+	store := NewRateLimitStoreMemory()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limit := domain.RateLimit{Rate: 2, Burst: 3}
+	take := func(key string) domain.RateLimitResult {
+		result, err := store.Take(context.Background(), key, limit)
+		assert.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, domain.RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond}, take("a"))
+	take("a")
+	assert.Equal(t, domain.RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond}, take("a"))
+	assert.Equal(t, domain.RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond}, take("a"))
+	assert.True(t, take("b").Allowed, "keys have their own buckets")
+
+	now = now.Add(250 * time.Millisecond)
+	result := take("a")
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 250*time.Millisecond, result.RetryAfter)
+
+	now = now.Add(250 * time.Millisecond)
+	assert.True(t, take("a").Allowed)
+	assert.False(t, take("a").Allowed)
+
+	now = now.Add(time.Hour)
+	result = take("a")
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "refills no further than the burst")
+	assert.Len(t, store.buckets, 1, "refilled buckets are swept")
+}
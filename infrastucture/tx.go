@@ -0,0 +1,121 @@
+package infrastucture
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+type txKey struct{}
+
+// sqlConn is what repositories run statements on: the pool, or the
+// transaction a caller opened with SQLTransactor.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func conn(ctx context.Context, db *sql.DB) sqlConn {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// txScope is a transaction a repository method runs its statements in.
+// Inside a caller's transaction it is that transaction, and committing or
+// rolling back is left to the caller.
+type txScope struct {
+	*sql.Tx
+	owned bool
+}
+
+func beginTx(ctx context.Context, db *sql.DB) (*txScope, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return &txScope{Tx: tx}, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txScope{Tx: tx, owned: true}, nil
+}
+
+func (t *txScope) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t *txScope) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// SQLTransactor runs functions in a database transaction that every SQL
+// repository on the same pool joins through the context.
+type SQLTransactor struct {
+	DB *sql.DB
+}
+
+func NewSQLTransactor(db *sql.DB) *SQLTransactor {
+	return &SQLTransactor{DB: db}
+}
+
+// WithinTx commits if fn succeeds and rolls back otherwise. Nested calls
+// join the outer transaction.
+func (t *SQLTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err, "transaction", 0)
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return dbError(tx.Commit(), "transaction", 0)
+}
+
+// SQLiteDSN makes transactions on dsn take SQLite's write lock when they
+// begin. A deferred transaction that reads before it writes cannot upgrade
+// its lock while another one holds a read lock, and fails with "database is
+// locked" instead of waiting for it.
+func SQLiteDSN(dsn string) string {
+	if strings.Contains(dsn, "_txlock=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_txlock=immediate"
+	}
+	return dsn + "?_txlock=immediate"
+}
+
+// MemoryTransactor serialises functions so the memory repositories see one
+// writer at a time. It cannot undo a function that fails halfway.
+type MemoryTransactor struct {
+	mu sync.Mutex
+}
+
+func NewMemoryTransactor() *MemoryTransactor {
+	return &MemoryTransactor{}
+}
+
+type memoryTxKey struct{}
+
+func (t *MemoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) == t {
+		return fn(ctx)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fn(context.WithValue(ctx, memoryTxKey{}, t))
+}
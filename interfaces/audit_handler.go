@@ -0,0 +1,94 @@
+package interfaces
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type AuditHandler struct {
+	service *application.AuditService
+}
+
+func NewAuditHandler(service *application.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// ListAuditHandler lists the audit log, newest first, filtered by book_id,
+// actor, action and an RFC 3339 since/until range.
+func (h *AuditHandler) ListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.service.List(r.Context(), query)
+	writeAuditPage(w, r, page, err)
+}
+
+// HistoryHandler lists the audit entries for one book, including books
+// that have since been deleted or purged.
+func (h *AuditHandler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
+		return
+	}
+	query, err := parseAuditQuery(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.service.History(r.Context(), ID, query)
+	writeAuditPage(w, r, page, err)
+}
+
+func writeAuditPage(w http.ResponseWriter, r *http.Request, page domain.AuditPage, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if page.NextBefore != 0 {
+		next := r.URL.Query()
+		next.Set("before", strconv.Itoa(page.NextBefore))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	if page.Entries == nil {
+		page.Entries = []domain.AuditEntry{}
+	}
+	w.Header().Set("Content-type", "application/json")
+	json.NewEncoder(w).Encode(page.Entries)
+}
+
+func parseAuditQuery(values url.Values) (domain.AuditQuery, error) {
+	query := domain.AuditQuery{
+		Actor:  values.Get("actor"),
+		Action: values.Get("action"),
+	}
+	for name, dst := range map[string]*int{"book_id": &query.BookID, "before": &query.Before, "limit": &query.Limit} {
+		if v := values.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		if v := values.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s %q: must be an RFC 3339 time", name, v)
+			}
+			*dst = &t
+		}
+	}
+	return query, nil
+}
@@ -0,0 +1,74 @@
+package interfaces_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/interfaces"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditHandler(t *testing.T) {
+	audits := infrastucture.NewAuditRepositoryMemory()
+	for _, entry := range []domain.AuditEntry{
+		{Actor: "api_key:1", Action: domain.AuditCreate, BookID: 1},
+		{Actor: "api_key:1", Action: domain.AuditCreate, BookID: 2},
+		{Actor: "api_key:2", Action: domain.AuditUpdate, BookID: 1},
+		{Actor: "api_key:1", Action: domain.AuditDelete, BookID: 1},
+	} {
+		assert.NoError(t, audits.RecordAudit(context.Background(), &entry))
+	}
+	h := interfaces.NewAuditHandler(application.NewAuditService(audits))
+	r := mux.NewRouter()
+	r.HandleFunc("/audit", h.ListAuditHandler).Methods("GET")
+	r.HandleFunc("/books/{id}/history", h.HistoryHandler).Methods("GET")
+
+	type testCase struct {
+		name           string
+		path           string
+		role           string
+		expectedStatus int
+		expectedIDs    []int
+		expectedLink   string
+	}
+	tests := []testCase{
+		{name: "all entries", path: "/audit", role: domain.RoleAdmin, expectedStatus: http.StatusOK, expectedIDs: []int{4, 3, 2, 1}},
+		{name: "filtered", path: "/audit?actor=api_key:1&action=create", role: domain.RoleAdmin, expectedStatus: http.StatusOK, expectedIDs: []int{2, 1}},
+		{name: "history", path: "/books/1/history", role: domain.RoleInventoryManager, expectedStatus: http.StatusOK, expectedIDs: []int{4, 3, 1}},
+		{name: "history next page", path: "/books/1/history?limit=2", role: domain.RoleAdmin, expectedStatus: http.StatusOK, expectedIDs: []int{4, 3}, expectedLink: `</books/1/history?before=3&limit=2>; rel="next"`},
+		{name: "history last page", path: "/books/1/history?limit=2&before=3", role: domain.RoleAdmin, expectedStatus: http.StatusOK, expectedIDs: []int{1}},
+		{name: "unknown book", path: "/books/9/history", role: domain.RoleAdmin, expectedStatus: http.StatusOK, expectedIDs: []int{}},
+		{name: "bad id", path: "/books/x/history", role: domain.RoleAdmin, expectedStatus: http.StatusBadRequest},
+		{name: "bad since", path: "/audit?since=yesterday", role: domain.RoleAdmin, expectedStatus: http.StatusBadRequest},
+		{name: "bad action", path: "/audit?action=archive", role: domain.RoleAdmin, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "viewer", path: "/audit", role: domain.RoleViewer, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := domain.WithPrincipal(context.Background(), domain.Principal{Subject: "user-1", Roles: []string{tc.role}})
+			req := httptest.NewRequest("GET", tc.path, nil).WithContext(ctx)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedLink, rr.Header().Get("Link"))
+			if tc.expectedStatus == http.StatusOK {
+				var entries []domain.AuditEntry
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&entries))
+				ids := []int{}
+				for _, entry := range entries {
+					ids = append(ids, entry.ID)
+				}
+				assert.Equal(t, tc.expectedIDs, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package interfaces
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type AuthHandler struct {
+	service *application.AuthService
+}
+
+func NewAuthHandler(service *application.AuthService) *AuthHandler {
+	return &AuthHandler{service: service}
+}
+
+type issueKeyRequest struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// issuedKey is the only response that ever carries a key's secret.
+type issuedKey struct {
+	*domain.APIKey
+	Key string `json:"key"`
+}
+
+func (h *AuthHandler) IssueKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req issueKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not Decode json")
+		return
+	}
+	key, secret, err := h.service.IssueAPIKey(r.Context(), req.Name, req.Roles)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issuedKey{APIKey: key, Key: secret})
+}
+
+func (h *AuthHandler) ListKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListAPIKeys(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []domain.APIKey{}
+	}
+	w.Header().Set("Content-type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *AuthHandler) RevokeKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
+		return
+	}
+	if err := h.service.RevokeAPIKey(r.Context(), ID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
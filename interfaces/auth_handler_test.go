@@ -0,0 +1,132 @@
+package interfaces_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/interfaces"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// newAuthRouter serves the key management routes and a /whoami route that
+// echoes the principal, all behind Authenticate.
+func newAuthRouter(t *testing.T) (*mux.Router, *application.AuthService) {
+	auth := application.NewAuthService(infrastucture.NewAPIKeyRepositoryMemory(), nil)
+	h := interfaces.NewAuthHandler(auth)
+	r := mux.NewRouter()
+	r.Use(interfaces.Authenticate(auth))
+	r.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := domain.PrincipalFromContext(r.Context())
+		json.NewEncoder(w).Encode(principal)
+	}).Methods("GET")
+	r.HandleFunc("/auth/keys", h.IssueKeyHandler).Methods("POST")
+	r.HandleFunc("/auth/keys", h.ListKeysHandler).Methods("GET")
+	r.HandleFunc("/auth/keys/{id}", h.RevokeKeyHandler).Methods("DELETE")
+	return r, auth
+}
+
+func issueKey(t *testing.T, auth *application.AuthService, name string, roles ...string) string {
+	_, secret, err := auth.IssueAPIKey(context.Background(), name, roles)
+	if err != nil {
+		t.Fatalf("Error issuing api key: %v", err)
+	}
+	return secret
+}
+
+func TestAuthenticate(t *testing.T) {
+	r, auth := newAuthRouter(t)
+	secret := issueKey(t, auth, "ci", "clerk")
+	revoked := issueKey(t, auth, "old")
+	assert.NoError(t, auth.RevokeAPIKey(context.Background(), 2))
+
+	type testCase struct {
+		name           string
+		header         string
+		value          string
+		expectedStatus int
+		expectedAuth   string
+	}
+	tests := []testCase{
+		{name: "no credentials", expectedStatus: http.StatusUnauthorized, expectedAuth: `Bearer realm="books"`},
+		{name: "api key header", header: "X-API-Key", value: secret, expectedStatus: http.StatusOK},
+		{name: "bearer api key", header: "Authorization", value: "Bearer " + secret, expectedStatus: http.StatusOK},
+		{name: "unknown key", header: "X-API-Key", value: "bk_unknown", expectedStatus: http.StatusUnauthorized, expectedAuth: `Bearer realm="books", error="invalid_token"`},
+		{name: "revoked key", header: "X-API-Key", value: revoked, expectedStatus: http.StatusUnauthorized, expectedAuth: `Bearer realm="books", error="invalid_token"`},
+		{name: "jwt without key set", header: "Authorization", value: "Bearer eyJhbGciOi", expectedStatus: http.StatusUnauthorized, expectedAuth: `Bearer realm="books", error="invalid_token"`},
+		{name: "basic auth", header: "Authorization", value: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusUnauthorized, expectedAuth: `Bearer realm="books"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedAuth, rr.Header().Get("WWW-Authenticate"))
+			if tc.expectedStatus == http.StatusOK {
+				var principal domain.Principal
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&principal))
+				assert.Equal(t, domain.Principal{Subject: "api_key:1", Name: "ci", Method: "api_key", Roles: []string{"clerk"}}, principal)
+			} else {
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAuthHandler(t *testing.T) {
+	r, auth := newAuthRouter(t)
+	admin := issueKey(t, auth, "ops", domain.RoleAdmin)
+	clerk := issueKey(t, auth, "ci", "clerk")
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/auth/keys", admin, `{"name":"deploy","roles":["viewer"]}`)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var issued struct {
+		ID    int      `json:"id"`
+		Name  string   `json:"name"`
+		Roles []string `json:"roles"`
+		Key   string   `json:"key"`
+	}
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&issued))
+	assert.Equal(t, 3, issued.ID)
+	assert.Equal(t, []string{"viewer"}, issued.Roles)
+	assert.Equal(t, http.StatusOK, do("GET", "/whoami", issued.Key, "").Code)
+
+	rr = do("GET", "/auth/keys", admin, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), issued.Key)
+	var keys []domain.APIKey
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&keys))
+	assert.Len(t, keys, 3)
+
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/auth/keys/3", admin, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/whoami", issued.Key, "").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/auth/keys/9", admin, "").Code)
+	assert.Equal(t, http.StatusBadRequest, do("DELETE", "/auth/keys/x", admin, "").Code)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, do("POST", "/auth/keys", admin, `{"name":""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/auth/keys", admin, `{`).Code)
+	assert.Equal(t, http.StatusForbidden, do("POST", "/auth/keys", clerk, `{"name":"sneaky","roles":["admin"]}`).Code)
+	assert.Equal(t, http.StatusForbidden, do("GET", "/auth/keys", clerk, "").Code)
+	assert.Equal(t, http.StatusForbidden, do("DELETE", "/auth/keys/1", clerk, "").Code)
+}
@@ -3,9 +3,16 @@ package interfaces
 import (
 	"book-apis/application"
 	"book-apis/domain"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -19,44 +26,145 @@ func NewBookHandler(service *application.BookService) *BookHandler {
 }
 
 func (s *BookHandler) GetAllBookHandler(w http.ResponseWriter, r *http.Request) {
-	books, err := s.service.GetAll()
+	listBooks(w, r, s.service.GetAll)
+}
+
+// TrashBookHandler lists deleted books with the same paging and filters as
+// GetAllBookHandler.
+func (s *BookHandler) TrashBookHandler(w http.ResponseWriter, r *http.Request) {
+	listBooks(w, r, s.service.Trash)
+}
+
+func listBooks(w http.ResponseWriter, r *http.Request, list func(context.Context, domain.BookQuery) (domain.BookPage, error)) {
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := list(r.Context(), query)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if page.NextCursor != "" {
+		next := r.URL.Query()
+		next.Set("cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	if page.Books == nil {
+		page.Books = []domain.Book{}
+	}
+	w.Header().Set("Content-type", "application/json")
+	json.NewEncoder(w).Encode(page.Books)
+}
+
+// parseBookQuery reads limit, cursor, sort (a field name, "-" prefixed for
+// descending), genre, author, currency, min_price, max_price and in_stock.
+// Price bounds are in currency, USD by default, and only match books priced
+// in it. Sorting by price needs a currency or a price bound.
+func parseBookQuery(values url.Values) (domain.BookQuery, error) {
+	query := domain.BookQuery{
+		Cursor:   values.Get("cursor"),
+		Genre:    values.Get("genre"),
+		Author:   values.Get("author"),
+		Currency: strings.ToUpper(values.Get("currency")),
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid limit %q", v)
+		}
+		query.Limit = limit
+	}
+
+	sort := values.Get("sort")
+	query.Desc = strings.HasPrefix(sort, "-")
+	query.Sort = strings.TrimPrefix(sort, "-")
+
+	currency := query.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	for name, dst := range map[string]**int64{"min_price": &query.MinPrice, "max_price": &query.MaxPrice} {
+		if v := values.Get(name); v != "" {
+			price, err := domain.ParseMoney(v, currency)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = &price.Amount
+			query.Currency = currency
+		}
+	}
+
+	if v := values.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid in_stock %q", v)
+		}
+		query.InStock = inStock
+	}
+	return query, nil
+}
+
+func (s *BookHandler) SearchBookHandler(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return
+		}
+	}
+	results, err := s.service.Search(r.Context(), r.URL.Query().Get("q"), limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	if results == nil {
+		results = []domain.SearchResult{}
+	}
 	w.Header().Set("Content-type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	json.NewEncoder(w).Encode(results)
 }
 
 func (s *BookHandler) GetBookHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	ID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Can not convert id to int", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
 		return
 	}
-	book, err := s.service.GetBook(ID)
+	book, err := s.service.GetBook(r.Context(), ID)
 	if err != nil {
-		http.Error(w, "Can not get Book", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", etag(&book))
 	w.Header().Set("Content-type", "application/json")
 	json.NewEncoder(w).Encode(book)
 }
 
+// bookInput is a book as sent in a request body. deleted_at is only set by
+// moving a book to the trash, so it is not read from clients.
+type bookInput struct {
+	domain.Book
+	DeletedAt json.RawMessage `json:"deleted_at"`
+}
+
 func (s *BookHandler) CreateBookHandler(w http.ResponseWriter, r *http.Request) {
-	var book domain.Book
-	err := json.NewDecoder(r.Body).Decode(&book)
+	var input bookInput
+	err := json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
-		http.Error(w, "Can not Decode json", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Can not Decode json")
 		return
 	}
-	newBook, err := s.service.CreateBook(&book)
+	newBook, err := s.service.CreateBook(r.Context(), &input.Book)
 	if err != nil {
-
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", etag(newBook))
 	w.Header().Set("Content-type", "application/json")
 	json.NewEncoder(w).Encode(newBook)
 }
@@ -65,37 +173,177 @@ func (s *BookHandler) UpdateBookHandler(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Can not convert id to int", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
 		return
 	}
 
-	var book *domain.Book
-	err = json.NewDecoder(r.Body).Decode(&book)
+	version, err := s.ifMatchVersion(r, id)
 	if err != nil {
-		http.Error(w, "Can not Decode json", http.StatusBadRequest)
+		writeIfMatchError(w, r, err)
 		return
 	}
-	updatedBook, e := s.service.UpdateBook(book, id)
+
+	var input *bookInput
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not Decode json")
+		return
+	}
+	var book *domain.Book
+	if input != nil {
+		book = &input.Book
+		book.Version = version
+	}
+	updatedBook, e := s.service.UpdateBook(r.Context(), book, id)
 	if e != nil {
-		http.Error(w, "Can not update book", http.StatusBadRequest)
+		writeError(w, r, e)
 		return
 	}
+	w.Header().Set("ETag", etag(updatedBook))
 	w.Header().Set("Content-type", "application/json")
 	json.NewEncoder(w).Encode(updatedBook)
 }
 
+// PatchBookHandler applies a JSON Merge Patch or JSON Patch, chosen by
+// Content-Type, to the book's JSON representation and saves the fields that
+// changed. If-Match is honoured but, unlike on PUT, not required: without
+// it the patch is applied again if the book changes before it is saved.
+func (s *BookHandler) PatchBookHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
+		return
+	}
+
+	var apply func(doc any, data []byte) (any, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchType:
+		apply = applyMergePatch
+	case jsonPatchType:
+		apply = applyJSONPatch
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s or %s", mergePatchType, jsonPatchType))
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not read body")
+		return
+	}
+
+	var version int
+	if r.Header.Get("If-Match") != "" {
+		if version, err = s.ifMatchVersion(r, ID); err != nil {
+			writeIfMatchError(w, r, err)
+			return
+		}
+	}
+
+	book, err := s.service.EditBook(r.Context(), ID, version, func(book domain.Book) (domain.Book, error) {
+		return patchBook(book, data, apply)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errPatchInvalid):
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+		case errors.Is(err, errPatchTest):
+			writeProblem(w, r, http.StatusConflict, err.Error())
+		default:
+			writeError(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("ETag", etag(book))
+	w.Header().Set("Content-type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// patchBook applies a patch document to book's JSON form and decodes the
+// result. Patches that cannot be applied or that touch read-only fields
+// are validation errors.
+func patchBook(book domain.Book, data []byte, apply func(doc any, data []byte) (any, error)) (domain.Book, error) {
+	encoded, err := json.Marshal(book)
+	if err != nil {
+		return domain.Book{}, err
+	}
+	doc, err := decodeJSON(encoded)
+	if err != nil {
+		return domain.Book{}, err
+	}
+	if doc, err = apply(doc, data); err != nil {
+		if errors.Is(err, errPatchInvalid) || errors.Is(err, errPatchTest) {
+			return domain.Book{}, err
+		}
+		return domain.Book{}, &domain.ValidationError{Message: err.Error()}
+	}
+	if encoded, err = json.Marshal(doc); err != nil {
+		return domain.Book{}, err
+	}
+
+	var patched domain.Book
+	if err := json.Unmarshal(encoded, &patched); err != nil {
+		return domain.Book{}, &domain.ValidationError{Message: "patched book is invalid: " + err.Error()}
+	}
+
+	var fields []domain.FieldError
+	if patched.ID != book.ID {
+		fields = append(fields, domain.FieldError{Field: "id", Message: "is read-only"})
+	}
+	if !patched.CreatedAt.Equal(book.CreatedAt) {
+		fields = append(fields, domain.FieldError{Field: "created_at", Message: "is read-only"})
+	}
+	if !patched.UpdatedAt.Equal(book.UpdatedAt) {
+		fields = append(fields, domain.FieldError{Field: "updated_at", Message: "is read-only"})
+	}
+	if patched.Version != book.Version {
+		fields = append(fields, domain.FieldError{Field: "version", Message: "is read-only"})
+	}
+	if (patched.DeletedAt == nil) != (book.DeletedAt == nil) {
+		fields = append(fields, domain.FieldError{Field: "deleted_at", Message: "is read-only"})
+	}
+	if len(fields) > 0 {
+		return domain.Book{}, &domain.ValidationError{Fields: fields}
+	}
+	return patched, nil
+}
+
 func (s *BookHandler) DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	ID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Can not convert id to int", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
 		return
 	}
-	err = s.service.DeleteBook(ID)
+	version, err := s.ifMatchVersion(r, ID)
 	if err != nil {
-		http.Error(w, "Can not delete Book", http.StatusInternalServerError)
+		writeIfMatchError(w, r, err)
+		return
+	}
+	err = s.service.DeleteBook(r.Context(), ID, version)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(http.StatusOK)
 }
+
+func (s *BookHandler) RestoreBookHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "Can not convert id to int")
+		return
+	}
+	book, err := s.service.RestoreBook(r.Context(), ID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(book))
+	w.Header().Set("Content-type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
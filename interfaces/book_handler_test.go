@@ -3,8 +3,10 @@ package interfaces_test
 import (
 	"book-apis/application"
 	"book-apis/domain"
+	"book-apis/infrastucture"
 	"book-apis/interfaces"
 	"book-apis/mocks"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -12,6 +14,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
@@ -33,13 +36,13 @@ func TestGetAllBooks(t *testing.T) {
 		{
 			name: "Successful response",
 			expected: []domain.Book{
-				{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10},
-				{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: "150", Stock: 20},
+				{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10},
+				{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20},
 			},
 			mockSetup: func() {
-				repo.On("GetAll").Return([]domain.Book{
-					{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10},
-					{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: "150", Stock: 20},
+				repo.On("GetAll", mock.Anything).Return([]domain.Book{
+					{Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10},
+					{Title: "Test Title 2", Author: "Test Author 2", Genre: "Adventure", Price: domain.NewMoney(15000, "USD"), Stock: 20},
 				}, nil).Once()
 			},
 			statusCode: http.StatusOK,
@@ -48,7 +51,7 @@ func TestGetAllBooks(t *testing.T) {
 			name:     "UnSuccessful response",
 			expected: nil,
 			mockSetup: func() {
-				repo.On("GetAll").Return([]domain.Book(nil), errors.New("Some error message")).Once()
+				repo.On("GetAll", mock.Anything).Return([]domain.Book(nil), errors.New("Some error message")).Once()
 			},
 			statusCode: http.StatusInternalServerError,
 		},
@@ -98,15 +101,23 @@ func TestGetOneBook(t *testing.T) {
 			name: "Successfull - get one Book",
 			ID:   "1",
 			expected: domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
 				mockRepo.On("GetBook", 1).Return(domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil).Once()
 			},
 			statusCode: http.StatusOK,
 		},
+		{
+			name: "Book not found",
+			ID:   "2",
+			mockSetup: func() {
+				mockRepo.On("GetBook", 2).Return(domain.Book{}, &domain.NotFoundError{Resource: "book", ID: 2}).Once()
+			},
+			statusCode: http.StatusNotFound,
+		},
 		{
 			name: "Error while converting ID",
 			ID:   "abc",
@@ -153,14 +164,14 @@ func TestCreateBook(t *testing.T) {
 			name:  "Succussful book create",
 			input: `{"title": "Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
 			expected: domain.Book{
-				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
 				mock.On("CreateBook", &domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}).Return(
 					&domain.Book{
-						Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+						Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 					}, nil)
 			},
 			statusCode:  http.StatusOK,
@@ -172,10 +183,10 @@ func TestCreateBook(t *testing.T) {
 			expected: domain.Book{},
 			mockSetup: func() {
 				mock.On("CreateBook", &domain.Book{
-					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}).Return(
 					&domain.Book{
-						Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+						Title: "Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 					}, nil)
 			},
 			statusCode:  http.StatusBadRequest,
@@ -217,6 +228,7 @@ func TestUpdateBook(t *testing.T) {
 	type testCase struct {
 		name        string
 		ID          string
+		ifMatch     string
 		input       string
 		expected    domain.Book
 		mockSetup   func()
@@ -225,17 +237,18 @@ func TestUpdateBook(t *testing.T) {
 	}
 	tests := []testCase{
 		{
-			name:  "Successfully update book",
-			ID:    "1",
-			input: `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
+			name:    "Successfully update book",
+			ID:      "1",
+			ifMatch: `"1"`,
+			input:   `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
 			expected: domain.Book{
-				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+				Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 			},
 			mockSetup: func() {
 				repo.On("UpdateBook", &domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 1,
 				}, 1).Return(&domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 			statusCode:  http.StatusOK,
@@ -244,13 +257,14 @@ func TestUpdateBook(t *testing.T) {
 		{
 			name:     "json decode fail",
 			ID:       "1",
+			ifMatch:  `"1"`,
 			input:    `{title: "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
 			expected: domain.Book{},
 			mockSetup: func() {
 				repo.On("UpdateBook", &domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 1,
 				}, 1).Return(&domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 			statusCode:  http.StatusBadRequest,
@@ -259,13 +273,14 @@ func TestUpdateBook(t *testing.T) {
 		{
 			name:     "ID retirval failed",
 			ID:       "a",
+			ifMatch:  `"1"`,
 			input:    `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
 			expected: domain.Book{},
 			mockSetup: func() {
 				repo.On("UpdateBook", &domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10, Version: 1,
 				}, 1).Return(&domain.Book{
-					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: "100", Stock: 10,
+					Title: "Updated Test Title 1", Author: "Test Author 1", Genre: "Horror", Price: domain.NewMoney(10000, "USD"), Stock: 10,
 				}, nil)
 			},
 			statusCode:  http.StatusBadRequest,
@@ -274,12 +289,44 @@ func TestUpdateBook(t *testing.T) {
 		{
 			name:     "update failed",
 			ID:       "10",
+			ifMatch:  `"1"`,
 			input:    `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
 			expected: domain.Book{},
 			mockSetup: func() {
 				repo.On("UpdateBook", mock.AnythingOfType("*domain.Book"), 10).Return(nil, errors.New("Oh no error!"))
 			},
-			statusCode:  http.StatusBadRequest,
+			statusCode:  http.StatusInternalServerError,
+			shouldError: true,
+		},
+		{
+			name:     "book not found",
+			ID:       "11",
+			ifMatch:  `"1"`,
+			input:    `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
+			expected: domain.Book{},
+			mockSetup: func() {
+				repo.On("UpdateBook", mock.AnythingOfType("*domain.Book"), 11).Return(nil, &domain.NotFoundError{Resource: "book", ID: 11})
+			},
+			statusCode:  http.StatusNotFound,
+			shouldError: true,
+		},
+		{
+			name:        "missing If-Match",
+			ID:          "12",
+			input:       `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
+			mockSetup:   func() {},
+			statusCode:  http.StatusPreconditionRequired,
+			shouldError: true,
+		},
+		{
+			name:    "stale version",
+			ID:      "13",
+			ifMatch: `"2"`,
+			input:   `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10}`,
+			mockSetup: func() {
+				repo.On("UpdateBook", mock.AnythingOfType("*domain.Book"), 13).Return(nil, &domain.StaleError{Resource: "book", ID: 13})
+			},
+			statusCode:  http.StatusPreconditionFailed,
 			shouldError: true,
 		},
 	}
@@ -291,6 +338,9 @@ func TestUpdateBook(t *testing.T) {
 			if err != nil {
 				t.Errorf("Failed to create request %v", err)
 			}
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
 			r := mux.NewRouter()
 			r.HandleFunc("/books/{id}", h.UpdateBookHandler).Methods("PUT")
 			response := httptest.NewRecorder()
@@ -317,6 +367,7 @@ func TestDeleteBook(t *testing.T) {
 	type testCase struct {
 		name       string
 		ID         string
+		ifMatch    string
 		expected   any
 		mockSetup  func()
 		statusCode int
@@ -324,10 +375,63 @@ func TestDeleteBook(t *testing.T) {
 	}
 	tests := []testCase{
 		{
-			name: "Succuessfull delete",
-			ID:   "1",
+			name:    "Succuessfull delete",
+			ID:      "1",
+			ifMatch: `"4"`,
 			mockSetup: func() {
-				mock.On("DeleteBook", 1).Return(nil)
+				mock.On("DeleteBook", 1, 4).Return(nil)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:    "Book not found",
+			ID:      "2",
+			ifMatch: `"4"`,
+			mockSetup: func() {
+				mock.On("DeleteBook", 2, 4).Return(&domain.NotFoundError{Resource: "book", ID: 2})
+			},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:    "Database unavailable",
+			ID:      "3",
+			ifMatch: `"4"`,
+			mockSetup: func() {
+				mock.On("DeleteBook", 3, 4).Return(&domain.UnavailableError{Err: errors.New("connection refused")})
+			},
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "Missing If-Match",
+			ID:         "4",
+			mockSetup:  func() {},
+			statusCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:    "Any version",
+			ID:      "5",
+			ifMatch: "*",
+			mockSetup: func() {
+				mock.On("DeleteBook", 5, 0).Return(nil)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:    "Weak tag never matches",
+			ID:      "6",
+			ifMatch: `W/"1", W/"2"`,
+			mockSetup: func() {
+				mock.On("GetBook", 6).Return(domain.Book{ID: 6, Version: 1}, nil)
+			},
+			statusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "Current version in list",
+			ID:      "7",
+			ifMatch: `"1", "3"`,
+			mockSetup: func() {
+				mock.On("GetBook", 7).Return(domain.Book{ID: 7, Version: 3}, nil)
+				mock.On("DeleteBook", 7, 3).Return(nil)
 			},
 			statusCode: http.StatusOK,
 		},
@@ -339,6 +443,9 @@ func TestDeleteBook(t *testing.T) {
 			if err != nil {
 				t.Errorf("Failed to create request %v", err)
 			}
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
 			r := mux.NewRouter()
 			r.HandleFunc("/books/{id}", h.DeleteBookHandler).Methods("DELETE")
 			response := httptest.NewRecorder()
@@ -350,3 +457,586 @@ func TestDeleteBook(t *testing.T) {
 		})
 	}
 }
+
+func TestBookHandler_MemoryStore(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	service := application.NewBookService(repo)
+	h := interfaces.NewBookHandler(service)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books", h.GetAllBookHandler).Methods("GET")
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+	r.HandleFunc("/books", h.CreateBookHandler).Methods("POST")
+	r.HandleFunc("/books/{id}", h.UpdateBookHandler).Methods("PUT")
+	r.HandleFunc("/books/{id}", h.DeleteBookHandler).Methods("DELETE")
+
+	serve := func(method, target, body, ifMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, req)
+		return response
+	}
+
+	// deleted_at is not read from clients, so the book is not created in
+	// the trash.
+	response := serve("POST", "/books", `{"title": "Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 10, "deleted_at": "2000-01-01T00:00:00Z"}`, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+	var created domain.Book
+	json.NewDecoder(response.Body).Decode(&created)
+	if created.ID != 1 || created.CreatedAt.IsZero() {
+		t.Errorf("Expected persisted book with ID and timestamps, but got %+v", created)
+	}
+
+	createdETag := response.Header().Get("ETag")
+	if createdETag != `"1"` {
+		t.Errorf("Expected ETag %q, but got %q", `"1"`, createdETag)
+	}
+
+	update := `{"title": "Updated Test Title 1", "author": "Test Author 1", "genre": "Horror", "price": "100", "stock": 5}`
+	response = serve("PUT", "/books/1", update, "")
+	if response.Code != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusPreconditionRequired, response.Code)
+	}
+	response = serve("PUT", "/books/1", update, createdETag)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+	// A second writer still holding the first ETag loses.
+	response = serve("PUT", "/books/1", update, createdETag)
+	if response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusPreconditionFailed, response.Code)
+	}
+
+	response = serve("GET", "/books/1", "", "")
+	var book domain.Book
+	json.NewDecoder(response.Body).Decode(&book)
+	if book.Title != "Updated Test Title 1" || book.Stock != 5 || book.Version != 2 {
+		t.Errorf("Expected updated book, but got %+v", book)
+	}
+	if etag := response.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("Expected ETag %q, but got %q", `"2"`, etag)
+	}
+
+	response = serve("DELETE", "/books/1", "", createdETag)
+	if response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusPreconditionFailed, response.Code)
+	}
+	response = serve("DELETE", "/books/1", "", `"2"`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+
+	response = serve("GET", "/books", "", "")
+	var books []domain.Book
+	json.NewDecoder(response.Body).Decode(&books)
+	if len(books) != 0 {
+		t.Errorf("Expected no books, but got %+v", books)
+	}
+}
+
+func TestWriteErrorProblemJSON(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	service := application.NewBookService(repo)
+	h := interfaces.NewBookHandler(service)
+
+	type testCase struct {
+		name       string
+		err        error
+		statusCode int
+		detail     string
+	}
+
+	tests := []testCase{
+		{name: "not found", err: &domain.NotFoundError{Resource: "book", ID: 1}, statusCode: http.StatusNotFound, detail: "book 1 not found"},
+		{name: "conflict", err: &domain.ConflictError{Message: "book already exists"}, statusCode: http.StatusConflict, detail: "book already exists"},
+		{name: "validation", err: &domain.ValidationError{Message: "title is required"}, statusCode: http.StatusUnprocessableEntity, detail: "title is required"},
+		{name: "unavailable", err: &domain.UnavailableError{Err: errors.New("database is locked")}, statusCode: http.StatusServiceUnavailable, detail: "service unavailable"},
+		{name: "timeout", err: &domain.TimeoutError{Err: context.DeadlineExceeded}, statusCode: http.StatusGatewayTimeout, detail: "timed out"},
+		{name: "unauthorized", err: &domain.UnauthorizedError{Message: "unknown api key"}, statusCode: http.StatusUnauthorized, detail: "unknown api key"},
+		{name: "forbidden", err: &domain.ForbiddenError{Message: "books:delete requires the admin role"}, statusCode: http.StatusForbidden, detail: "books:delete requires the admin role"},
+		{name: "unknown", err: errors.New("Some DB error"), statusCode: http.StatusInternalServerError, detail: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo.On("GetBook", 1).Return(domain.Book{}, tc.err).Once()
+			req, err := http.NewRequest("GET", "/books/1", nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			r := mux.NewRouter()
+			r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+			response := httptest.NewRecorder()
+			r.ServeHTTP(response, req)
+
+			if response.Code != tc.statusCode {
+				t.Errorf("Expected status code %d, but got %d", tc.statusCode, response.Code)
+			}
+			if ct := response.Header().Get("Content-type"); ct != "application/problem+json" {
+				t.Errorf("Expected problem+json content type, but got %q", ct)
+			}
+			var problem interfaces.Problem
+			json.NewDecoder(response.Body).Decode(&problem)
+			expected := interfaces.Problem{Type: "about:blank", Title: http.StatusText(tc.statusCode), Status: tc.statusCode, Detail: tc.detail, Instance: "/books/1"}
+			if !reflect.DeepEqual(problem, expected) {
+				t.Errorf("Expected problem %+v, but got %+v", expected, problem)
+			}
+		})
+	}
+}
+
+func TestDeleteBookHandler_Forbidden(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	repo.On("GetBook", 1).Return(domain.Book{ID: 1, Version: 2}, nil)
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+	req := httptest.NewRequest("DELETE", "/books/1", nil)
+	req.Header.Set("If-Match", `"2"`)
+	req = req.WithContext(domain.WithPrincipal(req.Context(), domain.Principal{Subject: "user-1", Roles: []string{domain.RoleClerk}}))
+	r := mux.NewRouter()
+	r.HandleFunc("/books/{id}", h.DeleteBookHandler).Methods("DELETE")
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, req)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, but got %d", http.StatusForbidden, response.Code)
+	}
+	if ct := response.Header().Get("Content-type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json content type, but got %q", ct)
+	}
+	var problem interfaces.Problem
+	json.NewDecoder(response.Body).Decode(&problem)
+	if problem.Detail != "books:delete requires the admin role" {
+		t.Errorf("Expected the missing role in the detail, but got %q", problem.Detail)
+	}
+	repo.AssertNotCalled(t, "DeleteBook", mock.Anything, mock.Anything)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	service := application.NewBookService(repo)
+	h := interfaces.NewBookHandler(service)
+
+	r := mux.NewRouter()
+	r.Use(interfaces.Timeout(time.Millisecond))
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+
+	// The driver error is opaque; the expired request deadline is what
+	// turns it into a 504.
+	repo.On("GetBook", 1).Return(domain.Book{}, errors.New("canceling query due to user request")).
+		Run(func(mock.Arguments) { time.Sleep(20 * time.Millisecond) }).Once()
+	req := httptest.NewRequest("GET", "/books/1", nil)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, req)
+	if response.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d, but got %d", http.StatusGatewayTimeout, response.Code)
+	}
+
+	repo.On("GetBook", 1).Return(domain.Book{}, errors.New("Some DB error")).Once()
+	r = mux.NewRouter()
+	r.Use(interfaces.Timeout(0))
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, httptest.NewRequest("GET", "/books/1", nil))
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got %d", http.StatusInternalServerError, response.Code)
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestCreateBookValidation(t *testing.T) {
+	repo := new(mocks.MockBookRepository)
+	service := application.NewBookService(repo)
+	h := interfaces.NewBookHandler(service)
+
+	req, err := http.NewRequest("POST", "/books", strings.NewReader(`{"title": "", "author": "Test Author 1", "price": {"amount": "-1.00", "currency": "USD"}, "stock": -1, "isbn": "978-0-306-40615-6"}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	r := mux.NewRouter()
+	r.HandleFunc("/books", h.CreateBookHandler).Methods("POST")
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, req)
+
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, but got %d", http.StatusUnprocessableEntity, response.Code)
+	}
+	var problem interfaces.Problem
+	json.NewDecoder(response.Body).Decode(&problem)
+	expected := []domain.FieldError{
+		{Field: "title", Message: "is required"},
+		{Field: "price", Message: "must not be negative"},
+		{Field: "stock", Message: "must be between 0 and 1000000"},
+		{Field: "isbn", Message: "must be a valid ISBN-10 or ISBN-13"},
+	}
+	if !reflect.DeepEqual(problem.Errors, expected) {
+		t.Errorf("Expected field errors %+v, but got %+v", expected, problem.Errors)
+	}
+	repo.AssertNotCalled(t, "CreateBook", mock.Anything)
+}
+
+func TestGetAllBooksPagination(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	for _, price := range []int64{500, 1500, 1000} {
+		repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title", Author: "Test Author", Genre: "Horror", Price: domain.NewMoney(price, "USD"), Stock: 1})
+	}
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		response := httptest.NewRecorder()
+		http.HandlerFunc(h.GetAllBookHandler).ServeHTTP(response, req)
+		return response
+	}
+	ids := func(response *httptest.ResponseRecorder) []int {
+		var books []domain.Book
+		json.NewDecoder(response.Body).Decode(&books)
+		var out []int
+		for _, b := range books {
+			out = append(out, b.ID)
+		}
+		return out
+	}
+
+	response := get("/books?limit=2&sort=-price&min_price=5.00")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+	if got := ids(response); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("Expected books [2 3], but got %v", got)
+	}
+	link := response.Header().Get("Link")
+	if !strings.HasPrefix(link, "</books?") || !strings.HasSuffix(link, `>; rel="next"`) {
+		t.Fatalf("Expected next link, but got %q", link)
+	}
+
+	response = get(strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+	if got := ids(response); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Expected books [1], but got %v", got)
+	}
+	if link := response.Header().Get("Link"); link != "" {
+		t.Errorf("Expected no next link on the last page, but got %q", link)
+	}
+
+	for _, target := range []string{"/books?limit=abc", "/books?min_price=cheap", "/books?in_stock=maybe"} {
+		if response := get(target); response.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, but got %d", target, http.StatusBadRequest, response.Code)
+		}
+	}
+	if response := get("/books?sort=stock"); response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, but got %d", http.StatusUnprocessableEntity, response.Code)
+	}
+}
+
+func TestGetAllBooksPriceCurrency(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	for _, price := range []domain.Money{domain.NewMoney(1000, "USD"), domain.NewMoney(1000, "JPY"), domain.NewMoney(2000, "EUR")} {
+		repo.CreateBook(context.Background(), &domain.Book{Title: "Test Title", Author: "Test Author", Genre: "Horror", Price: price, Stock: 1})
+	}
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+	tests := map[string][]int{
+		"/books":                              {1, 2, 3},
+		"/books?min_price=5.00":               {1},
+		"/books?max_price=50.00":              {1},
+		"/books?min_price=500&currency=jpy":   {2},
+		"/books?max_price=15.00&currency=EUR": nil,
+		"/books?sort=-price&currency=eur":     {3},
+	}
+	for target, expected := range tests {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		response := httptest.NewRecorder()
+		http.HandlerFunc(h.GetAllBookHandler).ServeHTTP(response, req)
+		if response.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code %d, but got %d", target, http.StatusOK, response.Code)
+		}
+
+		var books []domain.Book
+		json.NewDecoder(response.Body).Decode(&books)
+		var got []int
+		for _, b := range books {
+			got = append(got, b.ID)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected books %v, but got %v", target, expected, got)
+		}
+	}
+
+	// Minor units of different currencies do not order, so price sorts need one.
+	req, err := http.NewRequest("GET", "/books?sort=price", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	response := httptest.NewRecorder()
+	http.HandlerFunc(h.GetAllBookHandler).ServeHTTP(response, req)
+	if response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d, but got %d", http.StatusUnprocessableEntity, response.Code)
+	}
+}
+
+func TestSearchBooks(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	repo.CreateBook(context.Background(), &domain.Book{Title: "The Shining", Author: "Stephen King", Genre: "Horror", Price: domain.NewMoney(900, "USD")})
+	repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Frank Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD")})
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books/search", h.SearchBookHandler).Methods("GET")
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+
+	type testCase struct {
+		name       string
+		target     string
+		expected   []int
+		statusCode int
+	}
+	tests := []testCase{
+		{name: "prefix match", target: "/books/search?q=her", expected: []int{2}, statusCode: http.StatusOK},
+		{name: "no match", target: "/books/search?q=zzz", expected: []int{}, statusCode: http.StatusOK},
+		{name: "missing query", target: "/books/search", statusCode: http.StatusUnprocessableEntity},
+		{name: "bad limit", target: "/books/search?q=dune&limit=x", statusCode: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.target, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			response := httptest.NewRecorder()
+			r.ServeHTTP(response, req)
+
+			if response.Code != tc.statusCode {
+				t.Fatalf("Expected status code %d, but got %d", tc.statusCode, response.Code)
+			}
+			if tc.expected == nil {
+				return
+			}
+			var results []domain.SearchResult
+			json.NewDecoder(response.Body).Decode(&results)
+			ids := []int{}
+			for _, result := range results {
+				ids = append(ids, result.Book.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.expected) {
+				t.Errorf("Expected books %v, but got %v", tc.expected, ids)
+			}
+		})
+	}
+}
+
+func TestPatchBook(t *testing.T) {
+	type testCase struct {
+		name        string
+		contentType string
+		ifMatch     string
+		body        string
+		statusCode  int
+		expected    func(book domain.Book) bool
+	}
+	tests := []testCase{
+		{
+			name:        "merge patch changes stock only",
+			contentType: "application/merge-patch+json",
+			body:        `{"stock": 2}`,
+			statusCode:  http.StatusOK,
+			expected: func(book domain.Book) bool {
+				return book.Stock == 2 && book.Title == "Dune" && book.Genre == "SciFi" && book.Price == domain.NewMoney(1500, "USD")
+			},
+		},
+		{
+			name:        "merge patch null clears a field",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"genre": null, "price": {"amount": "9.99"}}`,
+			statusCode:  http.StatusOK,
+			expected: func(book domain.Book) bool {
+				return book.Genre == "" && book.Price == domain.NewMoney(999, "USD") && book.Stock == 3
+			},
+		},
+		{
+			name:        "json patch",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "test", "path": "/stock", "value": 3}, {"op": "replace", "path": "/title", "value": "Dune Messiah"}, {"op": "copy", "from": "/title", "path": "/genre"}]`,
+			statusCode:  http.StatusOK,
+			expected: func(book domain.Book) bool {
+				return book.Title == "Dune Messiah" && book.Genre == "Dune Messiah" && book.Author == "Herbert"
+			},
+		},
+		{
+			name:        "json patch test fails",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "test", "path": "/stock", "value": 4}, {"op": "replace", "path": "/stock", "value": 0}]`,
+			statusCode:  http.StatusConflict,
+		},
+		{
+			name:        "json patch missing path",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "replace", "path": "/publisher", "value": "Ace"}]`,
+			statusCode:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "malformed patch",
+			contentType: "application/json-patch+json",
+			body:        `{"op": "replace"}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "read-only field",
+			contentType: "application/merge-patch+json",
+			body:        `{"id": 7}`,
+			statusCode:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "deleted_at is read-only",
+			contentType: "application/merge-patch+json",
+			body:        `{"deleted_at": "2000-01-01T00:00:00Z"}`,
+			statusCode:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "patched book is invalid",
+			contentType: "application/merge-patch+json",
+			body:        `{"title": ""}`,
+			statusCode:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "stale If-Match",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"2"`,
+			body:        `{"stock": 2}`,
+			statusCode:  http.StatusPreconditionFailed,
+		},
+		{
+			name:        "matching If-Match",
+			contentType: "application/merge-patch+json",
+			ifMatch:     `"1"`,
+			body:        `{"stock": 2}`,
+			statusCode:  http.StatusOK,
+			expected: func(book domain.Book) bool {
+				return book.Stock == 2 && book.Version == 2
+			},
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json",
+			body:        `{"stock": 2}`,
+			statusCode:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := infrastucture.NewBookRepositoryMemory()
+			repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD"), Stock: 3})
+			h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+			r := mux.NewRouter()
+			r.HandleFunc("/books/{id}", h.PatchBookHandler).Methods("PATCH")
+
+			req, err := http.NewRequest("PATCH", "/books/1", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			response := httptest.NewRecorder()
+			r.ServeHTTP(response, req)
+
+			if response.Code != tc.statusCode {
+				t.Fatalf("Expected status code %d, but got %d: %s", tc.statusCode, response.Code, response.Body)
+			}
+			book, _ := repo.GetBook(context.Background(), 1)
+			if tc.expected == nil {
+				if book.Title != "Dune" || book.Stock != 3 {
+					t.Errorf("Expected book to be unchanged, but got %+v", book)
+				}
+				return
+			}
+			var patched domain.Book
+			json.NewDecoder(response.Body).Decode(&patched)
+			if !tc.expected(patched) || !reflect.DeepEqual(patched.Price, book.Price) || patched.Title != book.Title {
+				t.Errorf("Unexpected patched book %+v (stored %+v)", patched, book)
+			}
+		})
+	}
+}
+
+func TestTrashAndRestoreBook(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Herbert", Price: domain.NewMoney(1500, "USD")})
+	repo.CreateBook(context.Background(), &domain.Book{Title: "Emma", Author: "Austen", Price: domain.NewMoney(700, "USD")})
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/books", h.GetAllBookHandler).Methods("GET")
+	r.HandleFunc("/books/trash", h.TrashBookHandler).Methods("GET")
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+	r.HandleFunc("/books/{id}", h.DeleteBookHandler).Methods("DELETE")
+	r.HandleFunc("/books/{id}/restore", h.RestoreBookHandler).Methods("POST")
+
+	serve := func(method, target, ifMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		response := httptest.NewRecorder()
+		r.ServeHTTP(response, req)
+		return response
+	}
+	ids := func(response *httptest.ResponseRecorder) []int {
+		var books []domain.Book
+		json.NewDecoder(response.Body).Decode(&books)
+		ids := []int{}
+		for _, book := range books {
+			ids = append(ids, book.ID)
+		}
+		return ids
+	}
+
+	if response := serve("DELETE", "/books/1", `"1"`); response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+	if response := serve("GET", "/books/1", ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, but got %d", http.StatusNotFound, response.Code)
+	}
+	if got := ids(serve("GET", "/books", "")); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Expected books %v, but got %v", []int{2}, got)
+	}
+	if got := ids(serve("GET", "/books/trash", "")); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Expected trash %v, but got %v", []int{1}, got)
+	}
+
+	response := serve("POST", "/books/1/restore", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, response.Code)
+	}
+	if etag := response.Header().Get("ETag"); etag != `"3"` {
+		t.Errorf("Expected ETag %q, but got %q", `"3"`, etag)
+	}
+	if got := ids(serve("GET", "/books/trash", "")); len(got) != 0 {
+		t.Errorf("Expected empty trash, but got %v", got)
+	}
+	if response := serve("POST", "/books/1/restore", ""); response.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, but got %d", http.StatusNotFound, response.Code)
+	}
+	if response := serve("POST", "/books/x/restore", ""); response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, but got %d", http.StatusBadRequest, response.Code)
+	}
+}
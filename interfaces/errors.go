@@ -0,0 +1,99 @@
+package interfaces
+
+import (
+	"book-apis/domain"
+	"book-apis/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details body. Errors is an extension
+// member listing invalid fields on 422 responses.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []domain.FieldError `json:"errors,omitempty"`
+}
+
+func newProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+func (p Problem) write(w http.ResponseWriter) {
+	w.Header().Set("Content-type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	newProblem(r, status, detail).write(w)
+}
+
+// writeError answers with the problem for err. Server side failures are
+// logged with the request's logger since their detail, which may carry
+// driver or connection errors, is withheld from the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	status := errorStatus(err)
+	// Drivers do not all report a cancelled query as a deadline error, so
+	// fall back on the request's own deadline.
+	if status == http.StatusInternalServerError && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	if status >= http.StatusInternalServerError {
+		logging.FromContext(ctx).ErrorContext(ctx, "request failed", "status", status, "error", err)
+	} else {
+		logging.FromContext(ctx).DebugContext(ctx, "request rejected", "status", status, "error", err)
+	}
+	detail := err.Error()
+	switch status {
+	case http.StatusInternalServerError:
+		detail = ""
+	case http.StatusServiceUnavailable:
+		detail = domain.ErrUnavailable.Error()
+	case http.StatusGatewayTimeout:
+		detail = domain.ErrTimeout.Error()
+	}
+	problem := newProblem(r, status, detail)
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) && len(validationErr.Fields) > 0 {
+		problem.Detail = "One or more fields are invalid"
+		problem.Errors = validationErr.Fields
+	}
+	problem.write(w)
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrStale):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, domain.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
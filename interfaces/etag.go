@@ -0,0 +1,64 @@
+package interfaces
+
+import (
+	"book-apis/domain"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errPreconditionRequired = errors.New("If-Match header is required")
+
+// etag is the strong entity tag for book, derived from its version.
+func etag(book *domain.Book) string {
+	return `"` + strconv.Itoa(book.Version) + `"`
+}
+
+// ifMatchVersion resolves the If-Match header on a write to book ID into
+// the version the write must find; "*" resolves to 0, meaning any version.
+// When several tags are listed the current version is used if it is among
+// them. Weak tags never match, as If-Match uses strong comparison.
+func (s *BookHandler) ifMatchVersion(r *http.Request, ID int) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch header {
+	case "":
+		return 0, errPreconditionRequired
+	case "*":
+		return 0, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	book, err := s.service.GetBook(r.Context(), ID)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, book.Version) {
+		return 0, &domain.StaleError{Resource: "book", ID: ID}
+	}
+	return book.Version, nil
+}
+
+// writeIfMatchError reports a missing If-Match as 428 Precondition Required
+// and anything else through writeError.
+func writeIfMatchError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errPreconditionRequired) {
+		writeProblem(w, r, http.StatusPreconditionRequired, err.Error())
+		return
+	}
+	writeError(w, r, err)
+}
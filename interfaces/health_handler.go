@@ -0,0 +1,70 @@
+package interfaces
+
+import (
+	"book-apis/logging"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// ReadinessCheck is one dependency /readyz waits on.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type BuildInfo struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+type HealthHandler struct {
+	build  BuildInfo
+	checks []ReadinessCheck
+}
+
+func NewHealthHandler(build BuildInfo, checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{build: build, checks: checks}
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeHealth(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// LivenessHandler answers as long as the process can serve HTTP at all.
+func (h *HealthHandler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// ReadinessHandler runs every check and answers 503 if any of them fails,
+// so the instance is taken out of rotation until they pass again. Why a
+// check failed is only logged, as the route is open to anyone.
+func (h *HealthHandler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	result := healthStatus{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for _, check := range h.checks {
+		if err := check.Check(ctx); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "readiness check failed", "check", check.Name, "error", err)
+			result.Checks[check.Name] = "unavailable"
+			result.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		} else {
+			result.Checks[check.Name] = "ok"
+		}
+	}
+	writeHealth(w, status, result)
+}
+
+func (h *HealthHandler) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, h.build)
+}
@@ -0,0 +1,69 @@
+package interfaces_test
+
+import (
+	"book-apis/interfaces"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+	build := interfaces.BuildInfo{Commit: "abc123", BuildTime: "2024-01-02T03:04:05Z", GoVersion: "go1.23.1"}
+
+	type testCase struct {
+		name       string
+		checks     []interfaces.ReadinessCheck
+		handler    func(h *interfaces.HealthHandler) http.HandlerFunc
+		statusCode int
+		expected   map[string]any
+	}
+	tests := []testCase{
+		{
+			name:       "alive",
+			checks:     []interfaces.ReadinessCheck{{Name: "database", Check: down}},
+			handler:    func(h *interfaces.HealthHandler) http.HandlerFunc { return h.LivenessHandler },
+			statusCode: http.StatusOK,
+			expected:   map[string]any{"status": "ok"},
+		},
+		{
+			name:       "ready",
+			checks:     []interfaces.ReadinessCheck{{Name: "database", Check: ok}, {Name: "migrations", Check: ok}},
+			handler:    func(h *interfaces.HealthHandler) http.HandlerFunc { return h.ReadinessHandler },
+			statusCode: http.StatusOK,
+			expected:   map[string]any{"status": "ok", "checks": map[string]any{"database": "ok", "migrations": "ok"}},
+		},
+		{
+			name:       "not ready",
+			checks:     []interfaces.ReadinessCheck{{Name: "database", Check: down}, {Name: "migrations", Check: ok}},
+			handler:    func(h *interfaces.HealthHandler) http.HandlerFunc { return h.ReadinessHandler },
+			statusCode: http.StatusServiceUnavailable,
+			expected:   map[string]any{"status": "unavailable", "checks": map[string]any{"database": "unavailable", "migrations": "ok"}},
+		},
+		{
+			name:       "version",
+			handler:    func(h *interfaces.HealthHandler) http.HandlerFunc { return h.VersionHandler },
+			statusCode: http.StatusOK,
+			expected:   map[string]any{"commit": "abc123", "build_time": "2024-01-02T03:04:05Z", "go_version": "go1.23.1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := interfaces.NewHealthHandler(build, tc.checks...)
+			response := httptest.NewRecorder()
+			tc.handler(h)(response, httptest.NewRequest("GET", "/", nil))
+
+			assert.Equal(t, tc.statusCode, response.Code)
+			var body map[string]any
+			json.NewDecoder(response.Body).Decode(&body)
+			assert.Equal(t, tc.expected, body)
+		})
+	}
+}
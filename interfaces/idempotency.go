@@ -0,0 +1,117 @@
+package interfaces
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the response headers saved for replay. The rest,
+// such as X-Request-ID, describe the request that is being answered.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Last-Modified"}
+
+// bufferedResponse holds a handler's response until it is known whether
+// the writes behind it committed.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header { return w.header }
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// requestFingerprint hashes what a retry of r must repeat: the method,
+// path, the headers the book handlers act on, and the body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", r.Method, r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("If-Match"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotency answers write requests that repeat an Idempotency-Key with
+// the response saved for the first one, rejecting a key reused for a
+// different request with 422. Replayed responses carry an
+// Idempotent-Replayed header. Keys belong to the caller, or to its address
+// when it is not authenticated. Requests without the header are passed
+// through.
+func Idempotency(service *application.IdempotencyService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > application.MaxIdempotencyKeyLength {
+				writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must be at most %d characters", application.MaxIdempotencyKeyLength))
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, "Can not read request body")
+				return
+			}
+
+			var header http.Header
+			resp, replayed, err := service.Do(r.Context(), clientKey(r), key, requestFingerprint(r, body), func(ctx context.Context) domain.IdempotentResponse {
+				buf := &bufferedResponse{header: http.Header{}}
+				req := r.WithContext(ctx)
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				next.ServeHTTP(buf, req)
+
+				resp := domain.IdempotentResponse{Status: buf.status, Header: map[string]string{}, Body: buf.body.Bytes()}
+				if resp.Status == 0 {
+					resp.Status = http.StatusOK
+				}
+				header = buf.header
+				for _, name := range replayedHeaders {
+					if value := buf.header.Get(name); value != "" {
+						resp.Header[name] = value
+					}
+				}
+				return resp
+			})
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			if replayed {
+				for name, value := range resp.Header {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+			} else {
+				for name, values := range header {
+					w.Header()[name] = values
+				}
+			}
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package interfaces_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/interfaces"
+	"book-apis/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	tx := infrastucture.NewMemoryTransactor()
+	h := interfaces.NewBookHandler(application.NewAuditedBookService(repo, infrastucture.NewAuditRepositoryMemory(), tx))
+	idempotent := interfaces.Idempotency(application.NewIdempotencyService(infrastucture.NewIdempotencyRepositoryMemory(), tx, time.Hour))
+	r := mux.NewRouter()
+	r.Handle("/books", idempotent(http.HandlerFunc(h.CreateBookHandler))).Methods("POST")
+
+	book := `{"title":"Test Title 1","author":"Test Author 1","genre":"Horror","price":{"amount":"10.00","currency":"USD"},"stock":1}`
+	post := func(subject, key, body string) *httptest.ResponseRecorder {
+		ctx := domain.WithPrincipal(context.Background(), domain.Principal{Subject: subject, Roles: []string{domain.RoleAdmin}})
+		req := httptest.NewRequest("POST", "/books", strings.NewReader(body)).WithContext(ctx)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	count := func() int {
+		books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+		assert.NoError(t, err)
+		return len(books)
+	}
+
+	first := post("api_key:1", "key-1", book)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := post("api_key:1", "key-1", book)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Equal(t, 1, count())
+
+	mismatch := post("api_key:1", "key-1", strings.Replace(book, "Test Title 1", "Test Title 2", 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+	assert.Equal(t, "application/problem+json", mismatch.Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusOK, post("api_key:2", "key-1", book).Code, "keys are scoped to the caller")
+	assert.Equal(t, http.StatusOK, post("api_key:1", "", book).Code)
+	assert.Equal(t, 3, count())
+
+	invalid := post("api_key:1", "key-2", `{"title":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, invalid.Code)
+	replayed := post("api_key:1", "key-2", `{"title":""}`)
+	assert.Equal(t, invalid.Body.String(), replayed.Body.String())
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+
+	assert.Equal(t, http.StatusBadRequest, post("api_key:1", strings.Repeat("k", 256), book).Code)
+	assert.Equal(t, 3, count())
+}
+
+func TestIdempotency_Anonymous(t *testing.T) {
+	repo := infrastucture.NewBookRepositoryMemory()
+	tx := infrastucture.NewMemoryTransactor()
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+	idempotent := interfaces.Idempotency(application.NewIdempotencyService(infrastucture.NewIdempotencyRepositoryMemory(), tx, time.Hour))
+	r := mux.NewRouter()
+	r.Handle("/books", idempotent(http.HandlerFunc(h.CreateBookHandler))).Methods("POST")
+
+	post := func(remoteAddr, title string) *httptest.ResponseRecorder {
+		body := `{"title":"` + title + `","author":"Test Author 1","genre":"Horror","price":{"amount":"10.00","currency":"USD"},"stock":1}`
+		req := httptest.NewRequest("POST", "/books", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Idempotency-Key", "key-1")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, post("192.0.2.1:1234", "Test Title 1").Code)
+	other := post("192.0.2.2:1234", "Test Title 2")
+	assert.Equal(t, http.StatusOK, other.Code, "callers without a principal are told apart by address")
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	retry := post("192.0.2.1:5678", "Test Title 1")
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, books, 2)
+}
+
+func TestIdempotency_ConcurrentSQLite(t *testing.T) {
+	// A file shared by several connections, unlike :memory:.
+	db, err := sql.Open("sqlite3", infrastucture.SQLiteDSN("file:"+filepath.Join(t.TempDir(), "books.db")))
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Error applying migrations: %v", err)
+	}
+
+	repo, audits, tx := infrastucture.NewBookRepositorySQLite(db), infrastucture.NewAuditRepositorySQLite(db), infrastucture.NewSQLTransactor(db)
+	h := interfaces.NewBookHandler(application.NewAuditedBookService(repo, audits, tx))
+	idempotent := interfaces.Idempotency(application.NewIdempotencyService(infrastucture.NewIdempotencyRepositorySQLite(db), tx, time.Hour))
+	r := mux.NewRouter()
+	r.Handle("/books", idempotent(http.HandlerFunc(h.CreateBookHandler))).Methods("POST")
+	r.Handle("/books/{id}", idempotent(http.HandlerFunc(h.PatchBookHandler))).Methods("PATCH")
+
+	send := func(method, target, contentType, key, body string) int {
+		ctx := domain.WithPrincipal(context.Background(), domain.Principal{Subject: "api_key:1", Roles: []string{domain.RoleAdmin}})
+		req := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	concurrently := func(n int, fn func(i int) int) []int {
+		codes := make([]int, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i] = fn(i)
+			}()
+		}
+		wg.Wait()
+		return codes
+	}
+
+	// Racing retries of one request create one book between them.
+	book := `{"title":"Test Title 1","author":"Test Author 1","genre":"Horror","price":{"amount":"10.00","currency":"USD"},"stock":1}`
+	codes := concurrently(8, func(i int) int {
+		return send("POST", "/books", "application/json", "create", book)
+	})
+	assert.Contains(t, codes, http.StatusOK)
+	for _, code := range codes {
+		assert.Contains(t, []int{http.StatusOK, http.StatusConflict}, code)
+	}
+	books, err := repo.GetAll(context.Background(), domain.BookQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, books, 1)
+
+	// Unpinned patches under their own keys all land, retried as needed
+	// outside the transaction that claimed the key.
+	codes = concurrently(8, func(i int) int {
+		return send("PATCH", "/books/1", "application/merge-patch+json", fmt.Sprintf("patch-%d", i), fmt.Sprintf(`{"stock": %d}`, i+2))
+	})
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	patched, err := repo.GetBook(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, patched.Version)
+	entries, err := audits.ListAudit(context.Background(), domain.AuditQuery{BookID: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 9)
+}
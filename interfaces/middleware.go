@@ -0,0 +1,316 @@
+package interfaces
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/logging"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Timeout gives every request a deadline of d, after which database calls
+// made on its behalf are cancelled. A zero d leaves requests unbounded.
+func Timeout(d time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// statusRecorder remembers the status code and body size a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// code is the status sent, which is 200 if the handler never set one.
+func (w *statusRecorder) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// routeTemplate is the path template of the mux route r matched.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Metrics counts and times requests by method, mux route template and
+// status code. Labelling by template rather than path keeps /books/1 and
+// /books/2 in the same series.
+func Metrics(reg prometheus.Registerer) mux.MiddlewareFunc {
+	labels := []string{"method", "route", "code"}
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "books",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served.",
+	}, labels)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "books",
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, labels)
+	reg.MustRegister(requests, duration)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			values := []string{r.Method, routeTemplate(r), strconv.Itoa(rec.code())}
+			requests.WithLabelValues(values...).Inc()
+			duration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID accepts caller supplied IDs that are safe to echo back
+// and log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestLogger tags each request with an X-Request-ID, taken from the
+// request when it carries a usable one, and puts a logger bound to that ID
+// in the request context. Once the request is served it logs one line
+// describing it.
+func RequestLogger(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(requestIDHeader, id)
+
+			l := logger.With("request_id", id)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				l = l.With("trace_id", sc.TraceID().String())
+			}
+			ctx := logging.WithRequestID(logging.WithLogger(r.Context(), l), id)
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			level := slog.LevelInfo
+			if rec.code() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			l.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", routeTemplate(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.code()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int("bytes", rec.bytes),
+			)
+		})
+	}
+}
+
+// Tracing starts a server span per request, continuing the caller's trace
+// when the request carries a W3C traceparent header.
+func Tracing(tp trace.TracerProvider) mux.MiddlewareFunc {
+	tracer := tp.Tracer("book-apis/interfaces")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			route := routeTemplate(r)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			span.SetAttributes(semconv.HTTPResponseStatusCode(rec.code()))
+			if rec.code() >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rec.code()))
+			}
+		})
+	}
+}
+
+// credential is the bearer token or X-API-Key the request carries.
+func credential(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// Authenticate rejects requests without a valid API key or JWT with 401
+// and puts the principal of the rest in the request context.
+func Authenticate(auth *application.AuthService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cred := credential(r)
+			if cred == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="books"`)
+				writeError(w, r, &domain.UnauthorizedError{Message: "an API key or bearer token is required"})
+				return
+			}
+			principal, err := auth.Authenticate(r.Context(), cred)
+			if err != nil {
+				if errors.Is(err, domain.ErrUnauthorized) {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="books", error="invalid_token"`)
+				}
+				writeError(w, r, err)
+				return
+			}
+
+			ctx := domain.WithPrincipal(r.Context(), principal)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("principal", principal.Subject))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// clientAddress is the host of the connection a request came in on.
+// X-Forwarded-For is not trusted.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientKey is who a request is from, for rate limits and idempotency
+// keys: the authenticated principal, or the client's address when there is
+// none.
+func clientKey(r *http.Request) string {
+	if principal, ok := domain.PrincipalFromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	return "ip:" + clientAddress(r)
+}
+
+// ceilSeconds rounds d up to whole seconds as the RateLimit and
+// Retry-After headers want them.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit takes a token from the caller's read bucket for GET and HEAD
+// requests and from its write bucket for everything else, answering with
+// 429 once the bucket is empty. Every response carries the RateLimit-*
+// headers of the bucket it drew on. Requests are let through when the
+// store fails, as the limits protect the database rather than guard data.
+func RateLimit(store domain.RateLimitStore, read, write domain.RateLimit) mux.MiddlewareFunc {
+	return rateLimit(store, func(r *http.Request) (string, string, domain.RateLimit) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return "read:" + clientKey(r), "read requests", read
+		}
+		return "write:" + clientKey(r), "write requests", write
+	})
+}
+
+// RateLimitByAddress takes a token from one bucket per client address for
+// every request. It goes in front of Authenticate, so that a client sending
+// bad credentials is throttled too.
+func RateLimitByAddress(store domain.RateLimitStore, limit domain.RateLimit) mux.MiddlewareFunc {
+	return rateLimit(store, func(r *http.Request) (string, string, domain.RateLimit) {
+		return "address:" + clientAddress(r), "requests from this address", limit
+	})
+}
+
+// rateLimit draws on the bucket bucket picks for each request: its store
+// key, what it counts for the 429 detail, and its limit.
+func rateLimit(store domain.RateLimitStore, bucket func(r *http.Request) (string, string, domain.RateLimit)) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, counted, limit := bucket(r)
+			ctx := r.Context()
+			result, err := store.Take(ctx, key, limit)
+			if err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "rate limit store failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				writeProblem(w, r, http.StatusTooManyRequests, "rate limit for "+counted+" exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package interfaces_test
+
+import (
+	"book-apis/application"
+	"book-apis/domain"
+	"book-apis/infrastucture"
+	"book-apis/interfaces"
+	"book-apis/logging"
+	"book-apis/mocks"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := mux.NewRouter()
+	r.Use(interfaces.Metrics(reg))
+	r.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+
+	for _, path := range []string{"/books/1", "/books/2", "/books/404"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	expected := `
+# HELP books_http_requests_total HTTP requests served.
+# TYPE books_http_requests_total counter
+books_http_requests_total{code="200",method="GET",route="/books/{id}"} 2
+books_http_requests_total{code="404",method="GET",route="/books/{id}"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "books_http_requests_total"))
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "books_http_request_duration_seconds"))
+}
+
+func TestRequestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	repo := new(mocks.MockBookRepository)
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+	r := mux.NewRouter()
+	r.Use(interfaces.RequestLogger(logger))
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+
+	type testCase struct {
+		name      string
+		requestID string
+		err       error
+		entries   []map[string]any
+	}
+	tests := []testCase{
+		{
+			name:      "propagated request ID",
+			requestID: "abc-123",
+			err:       errors.New("Some DB error"),
+			entries: []map[string]any{
+				{"level": "ERROR", "msg": "request failed", "request_id": "abc-123", "status": float64(500), "error": "Some DB error"},
+				{"level": "ERROR", "msg": "request", "request_id": "abc-123", "method": "GET", "route": "/books/{id}", "path": "/books/1", "status": float64(500)},
+			},
+		},
+		{
+			name:      "generated request ID",
+			requestID: "not valid",
+			err:       &domain.NotFoundError{Resource: "book", ID: 1},
+			entries: []map[string]any{
+				{"level": "DEBUG", "msg": "request rejected", "status": float64(404), "error": "book 1 not found"},
+				{"level": "INFO", "msg": "request", "method": "GET", "route": "/books/{id}", "path": "/books/1", "status": float64(404)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out.Reset()
+			repo.On("GetBook", 1).Return(domain.Book{}, tc.err).Once()
+			req := httptest.NewRequest("GET", "/books/1", nil)
+			req.Header.Set("X-Request-ID", tc.requestID)
+			response := httptest.NewRecorder()
+			r.ServeHTTP(response, req)
+
+			id := response.Header().Get("X-Request-ID")
+			if tc.requestID == "abc-123" {
+				assert.Equal(t, tc.requestID, id)
+			} else {
+				assert.Len(t, id, 32)
+			}
+
+			dec := json.NewDecoder(&out)
+			for _, expected := range tc.entries {
+				var entry map[string]any
+				if err := dec.Decode(&entry); err != nil {
+					t.Fatalf("Expected log entry %v: %v", expected["msg"], err)
+				}
+				assert.Equal(t, id, entry["request_id"])
+				for k, v := range expected {
+					assert.Equal(t, v, entry[k], k)
+				}
+			}
+			assert.False(t, dec.More())
+		})
+	}
+}
+
+func TestRequestLogger_Context(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(interfaces.RequestLogger(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))))
+	var id string
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		id = logging.RequestID(r.Context())
+	})
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, response.Header().Get("X-Request-ID"))
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	repo := infrastucture.NewBookRepositoryTracing(infrastucture.NewBookRepositoryMemory(), tp)
+	repo.CreateBook(context.Background(), &domain.Book{Title: "Dune", Author: "Frank Herbert", Genre: "SciFi", Price: domain.NewMoney(1500, "USD")})
+	recorder.Reset()
+
+	h := interfaces.NewBookHandler(application.NewBookService(repo))
+	r := mux.NewRouter()
+	r.Use(interfaces.Tracing(tp))
+	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+
+	type testCase struct {
+		name       string
+		path       string
+		statusCode int
+		spanStatus codes.Code
+	}
+	tests := []testCase{
+		{name: "found", path: "/books/1", statusCode: http.StatusOK, spanStatus: codes.Unset},
+		{name: "not found", path: "/books/2", statusCode: http.StatusNotFound, spanStatus: codes.Error},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder.Reset()
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			response := httptest.NewRecorder()
+			r.ServeHTTP(response, req)
+			assert.Equal(t, tc.statusCode, response.Code)
+
+			spans := recorder.Ended()
+			if len(spans) != 3 {
+				t.Fatalf("Expected 3 spans, but got %d", len(spans))
+			}
+			repoSpan, serviceSpan, serverSpan := spans[0], spans[1], spans[2]
+			assert.Equal(t, "BookRepository.GetBook", repoSpan.Name())
+			assert.Equal(t, "BookService.GetBook", serviceSpan.Name())
+			assert.Equal(t, "GET /books/{id}", serverSpan.Name())
+
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext().TraceID().String())
+			assert.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+			assert.Equal(t, serverSpan.SpanContext().SpanID(), serviceSpan.Parent().SpanID())
+			assert.Equal(t, serviceSpan.SpanContext().SpanID(), repoSpan.Parent().SpanID())
+			assert.Equal(t, tc.spanStatus, repoSpan.Status().Code)
+			assert.Contains(t, serverSpan.Attributes(), attribute.Int("http.response.status_code", tc.statusCode))
+		})
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	return domain.RateLimitResult{}, errors.New("Some store error")
+}
+
+func TestRateLimit(t *testing.T) {
+	// Buckets refill too slowly to matter within the test.
+	read, write := domain.RateLimit{Rate: 0.01, Burst: 2}, domain.RateLimit{Rate: 0.01, Burst: 1}
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subject := r.Header.Get("X-Subject"); subject != "" {
+				r = r.WithContext(domain.WithPrincipal(r.Context(), domain.Principal{Subject: subject}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, interfaces.RateLimit(infrastucture.NewRateLimitStoreMemory(), read, write))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	r.HandleFunc("/books", ok).Methods("GET", "POST")
+
+	type testCase struct {
+		name              string
+		method            string
+		subject           string
+		remoteAddr        string
+		expectedStatus    int
+		expectedRemaining string
+		expectedRetry     string
+	}
+	tests := []testCase{
+		{name: "first read", method: "GET", subject: "api_key:1", expectedStatus: http.StatusOK, expectedRemaining: "1"},
+		{name: "second read", method: "GET", subject: "api_key:1", expectedStatus: http.StatusOK, expectedRemaining: "0"},
+		{name: "read budget spent", method: "GET", subject: "api_key:1", expectedStatus: http.StatusTooManyRequests, expectedRemaining: "0", expectedRetry: "100"},
+		{name: "write budget is separate", method: "POST", subject: "api_key:1", expectedStatus: http.StatusOK, expectedRemaining: "0"},
+		{name: "write budget spent", method: "POST", subject: "api_key:1", expectedStatus: http.StatusTooManyRequests, expectedRemaining: "0", expectedRetry: "100"},
+		{name: "other key", method: "GET", subject: "api_key:2", expectedStatus: http.StatusOK, expectedRemaining: "1"},
+		{name: "anonymous by address", method: "POST", remoteAddr: "192.0.2.1:1234", expectedStatus: http.StatusOK, expectedRemaining: "0"},
+		{name: "same address other port", method: "POST", remoteAddr: "192.0.2.1:5678", expectedStatus: http.StatusTooManyRequests, expectedRemaining: "0", expectedRetry: "100"},
+		{name: "other address", method: "POST", remoteAddr: "192.0.2.2:1234", expectedStatus: http.StatusOK, expectedRemaining: "0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/books", nil)
+			if tc.subject != "" {
+				req.Header.Set("X-Subject", tc.subject)
+			}
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.NotEmpty(t, rr.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, tc.expectedRemaining, rr.Header().Get("RateLimit-Remaining"))
+			assert.NotEmpty(t, rr.Header().Get("RateLimit-Reset"))
+			assert.Equal(t, tc.expectedRetry, rr.Header().Get("Retry-After"))
+			if tc.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestRateLimitByAddress_BeforeAuthenticate(t *testing.T) {
+	limits := infrastucture.NewRateLimitStoreMemory()
+	auth := application.NewAuthService(infrastucture.NewAPIKeyRepositoryMemory(), nil)
+	limit := domain.RateLimit{Rate: 0.01, Burst: 3}
+	r := mux.NewRouter()
+	r.Use(interfaces.RateLimitByAddress(limits, limit), interfaces.Authenticate(auth), interfaces.RateLimit(limits, limit, limit))
+	r.HandleFunc("/books", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }).Methods("GET")
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/books", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-API-Key", "bk_unknown")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get("192.0.2.1:1234").Code)
+	}
+	rr := get("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "100", rr.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusUnauthorized, get("192.0.2.2:1234").Code)
+}
+
+func TestRateLimit_StoreFailure(t *testing.T) {
+	limit := domain.RateLimit{Rate: 1, Burst: 1}
+	handler := interfaces.RateLimit(failingRateLimitStore{}, limit, limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/books", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
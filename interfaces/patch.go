@@ -0,0 +1,357 @@
+package interfaces
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// errPatchInvalid marks a patch document that is not well formed, and
+// errPatchTest a JSON Patch "test" operation that did not hold.
+var (
+	errPatchInvalid = errors.New("invalid patch document")
+	errPatchTest    = errors.New("patch test failed")
+)
+
+// decodeJSON decodes data keeping numbers as json.Number so amounts are
+// never rounded through a float.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func applyMergePatch(doc any, data []byte) (any, error) {
+	patch, err := decodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPatchInvalid, err)
+	}
+	return mergePatch(doc, patch), nil
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch to doc. Operations run in
+// order and the first failure aborts the whole patch.
+func applyJSONPatch(doc any, data []byte) (any, error) {
+	var ops []patchOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPatchInvalid, err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return doc, nil
+}
+
+func (op patchOperation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: missing path", errPatchInvalid)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var from []string
+	switch op.Op {
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: missing from", errPatchInvalid)
+		}
+		if from, err = parsePointer(*op.From); err != nil {
+			return nil, err
+		}
+	}
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: missing value", errPatchInvalid)
+		}
+		if value, err = decodeJSON(op.Value); err != nil {
+			return nil, fmt.Errorf("%w: %v", errPatchInvalid, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, value)
+	case "remove":
+		return removeValue(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "move":
+		if strings.HasPrefix(*op.Path, *op.From+"/") {
+			return nil, fmt.Errorf("cannot move %q into its own child", *op.From)
+		}
+		if value, err = getValue(doc, from); err != nil {
+			return nil, err
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "copy":
+		if value, err = getValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, deepCopy(value))
+	case "test":
+		actual, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(actual, value) {
+			return nil, fmt.Errorf("%w: value at %q differs", errPatchTest, *op.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", errPatchInvalid, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", errPatchInvalid, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses token as an index into an array of length n. When
+// appending, "-" and n itself are allowed too.
+func arrayIndex(token string, n int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (i == n && !appending) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]any:
+			v, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, fmt.Errorf("cannot index into %q", token)
+		}
+	}
+	return doc, nil
+}
+
+// update walks to the parent of the last token in path and replaces it with
+// whatever fn makes of it.
+func update(doc any, path []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch c := doc.(type) {
+	case map[string]any:
+		child, ok := c[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path member %q not found", path[0])
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[path[0]] = child
+		return c, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(c[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	default:
+		return nil, fmt.Errorf("cannot index into %q", path[0])
+	}
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(parent any, token string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a scalar", token)
+		}
+	})
+}
+
+func removeValue(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return update(doc, path, func(parent any, token string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			if _, ok := c[token]; !ok {
+				return nil, fmt.Errorf("path member %q not found", token)
+			}
+			delete(c, token)
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+		}
+	})
+}
+
+func deepCopy(v any) any {
+	switch c := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(c))
+		for k, v := range c {
+			m[k] = deepCopy(v)
+		}
+		return m
+	case []any:
+		s := make([]any, len(c))
+		for i, v := range c {
+			s[i] = deepCopy(v)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// jsonEqual compares decoded JSON values, treating numbers as equal when
+// they are numerically equal.
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		r1, ok1 := new(big.Rat).SetString(x.String())
+		r2, ok2 := new(big.Rat).SetString(y.String())
+		return ok1 && ok2 && r1.Cmp(r2) == 0
+	default:
+		return a == b
+	}
+}
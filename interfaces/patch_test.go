@@ -0,0 +1,71 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	type testCase struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+		errIs    error
+	}
+	tests := []testCase{
+		{name: "add member", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, expected: `{"baz":"qux","foo":"bar"}`},
+		{name: "add array element", doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, expected: `{"foo":["bar","qux","baz"]}`},
+		{name: "append to array", doc: `{"foo":[1]}`, patch: `[{"op":"add","path":"/foo/-","value":2}]`, expected: `{"foo":[1,2]}`},
+		{name: "remove array element", doc: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, expected: `{"foo":["bar","baz"]}`},
+		{name: "move", doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "escaped pointer", doc: `{"a/b":1,"m~n":2}`, patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"test","path":"/m~0n","value":2}]`, expected: `{"a/b":3,"m~n":2}`},
+		{name: "test numbers numerically", doc: `{"n":1}`, patch: `[{"op":"test","path":"/n","value":1.0}]`, expected: `{"n":1}`},
+		{name: "replace root", doc: `{"a":1}`, patch: `[{"op":"replace","path":"","value":{"b":2}}]`, expected: `{"b":2}`},
+		{name: "test fails", doc: `{"foo":["a"]}`, patch: `[{"op":"test","path":"/foo","value":["b"]}]`, errIs: errPatchTest},
+		{name: "unknown op", doc: `{}`, patch: `[{"op":"frobnicate","path":"/a"}]`, errIs: errPatchInvalid},
+		{name: "missing value", doc: `{}`, patch: `[{"op":"add","path":"/a"}]`, errIs: errPatchInvalid},
+		{name: "not an array", doc: `{}`, patch: `{"op":"add"}`, errIs: errPatchInvalid},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := decodeJSON([]byte(tc.doc))
+			assert.NoError(t, err)
+			result, err := applyJSONPatch(doc, []byte(tc.patch))
+			if tc.errIs != nil {
+				assert.ErrorIs(t, err, tc.errIs)
+				return
+			}
+			assert.NoError(t, err)
+			encoded, _ := json.Marshal(result)
+			assert.JSONEq(t, tc.expected, string(encoded))
+		})
+	}
+
+	for _, patch := range []string{
+		`[{"op":"remove","path":"/missing"}]`,
+		`[{"op":"add","path":"/a/b","value":1}]`,
+		`[{"op":"add","path":"/list/5","value":1}]`,
+		`[{"op":"add","path":"/list/01","value":1}]`,
+		`[{"op":"move","from":"/list","path":"/list/0"}]`,
+	} {
+		doc, _ := decodeJSON([]byte(`{"list":[0]}`))
+		_, err := applyJSONPatch(doc, []byte(patch))
+		assert.Error(t, err, patch)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	doc, err := decodeJSON([]byte(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`))
+	assert.NoError(t, err)
+	result, err := applyMergePatch(doc, []byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`))
+	assert.NoError(t, err)
+	encoded, _ := json.Marshal(result)
+	assert.JSONEq(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`, string(encoded))
+
+	_, err = applyMergePatch(doc, []byte(`{"title":`))
+	assert.ErrorIs(t, err, errPatchInvalid)
+}
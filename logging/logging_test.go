@@ -0,0 +1,43 @@
+package logging_test
+
+import (
+	"book-apis/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+	}
+	for level, expected := range tests {
+		assert.Equal(t, expected, logging.ParseLevel(level), level)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, slog.Default(), logging.FromContext(ctx))
+	assert.Equal(t, "", logging.RequestID(ctx))
+
+	var out bytes.Buffer
+	logger := logging.New(&out, "warn").With("request_id", "abc")
+	ctx = logging.WithRequestID(logging.WithLogger(ctx, logger), "abc")
+	assert.Equal(t, "abc", logging.RequestID(ctx))
+
+	logging.FromContext(ctx).Info("dropped")
+	logging.FromContext(ctx).Warn("kept")
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, "abc", entry["request_id"])
+}
@@ -2,40 +2,405 @@ package main
 
 import (
 	"book-apis/application"
+	"book-apis/config"
+	"book-apis/domain"
 	"book-apis/infrastucture"
 	"book-apis/interfaces"
+	"book-apis/logging"
+	"book-apis/migrations"
+	"book-apis/tracing"
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/XSAM/otelsql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-func routes(h *interfaces.BookHandler) *mux.Router {
+// commit and buildTime are set at build time with
+// -ldflags "-X main.commit=... -X main.buildTime=...".
+var (
+	commit    string
+	buildTime string
+)
+
+// buildInfo falls back on the VCS stamp the go command embeds when the
+// ldflags were not set.
+func buildInfo() interfaces.BuildInfo {
+	info := interfaces.BuildInfo{Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+func routes(h *interfaces.BookHandler, keys *interfaces.AuthHandler, audit *interfaces.AuditHandler, auth *application.AuthService, limits domain.RateLimitStore, idempotency *application.IdempotencyService, health *interfaces.HealthHandler, reg *prometheus.Registry, cfg *config.Config) *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/books", h.GetAllBookHandler).Methods("GET")
-	r.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
-	r.HandleFunc("/books", h.CreateBookHandler).Methods("POST")
-	r.HandleFunc("/books/{id}", h.UpdateBookHandler).Methods("PUT")
+	r.Use(interfaces.Tracing(otel.GetTracerProvider()), interfaces.RequestLogger(slog.Default()), interfaces.Metrics(reg), interfaces.Timeout(cfg.RequestTimeout))
+	r.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{})).Methods("GET")
+	r.HandleFunc("/healthz", health.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", health.ReadinessHandler).Methods("GET")
+	r.HandleFunc("/version", health.VersionHandler).Methods("GET")
+
+	api := r.NewRoute().Subrouter()
+	if cfg.RateLimit.Enabled {
+		address := domain.RateLimit{Rate: cfg.RateLimit.Address.Rate, Burst: cfg.RateLimit.Address.Burst}
+		api.Use(interfaces.RateLimitByAddress(limits, address))
+	}
+	if cfg.Auth.Enabled {
+		api.Use(interfaces.Authenticate(auth))
+	}
+	if cfg.RateLimit.Enabled {
+		read := domain.RateLimit{Rate: cfg.RateLimit.Read.Rate, Burst: cfg.RateLimit.Read.Burst}
+		write := domain.RateLimit{Rate: cfg.RateLimit.Write.Rate, Burst: cfg.RateLimit.Write.Burst}
+		api.Use(interfaces.RateLimit(limits, read, write))
+	}
+	api.HandleFunc("/books", h.GetAllBookHandler).Methods("GET")
+	if cfg.Features.Search {
+		api.HandleFunc("/books/search", h.SearchBookHandler).Methods("GET")
+	}
+	api.HandleFunc("/books/trash", h.TrashBookHandler).Methods("GET")
+	api.HandleFunc("/books/{id}", h.GetBookHandler).Methods("GET")
+	// Key management stays out of this: replaying an issued key would mean
+	// storing its secret.
+	idempotent := interfaces.Idempotency(idempotency)
+	api.Handle("/books", idempotent(http.HandlerFunc(h.CreateBookHandler))).Methods("POST")
+	api.Handle("/books/{id}", idempotent(http.HandlerFunc(h.UpdateBookHandler))).Methods("PUT")
+	api.Handle("/books/{id}", idempotent(http.HandlerFunc(h.PatchBookHandler))).Methods("PATCH")
+	api.Handle("/books/{id}", idempotent(http.HandlerFunc(h.DeleteBookHandler))).Methods("DELETE")
+	api.Handle("/books/{id}/restore", idempotent(http.HandlerFunc(h.RestoreBookHandler))).Methods("POST")
+	api.HandleFunc("/books/{id}/history", audit.HistoryHandler).Methods("GET")
+	api.HandleFunc("/audit", audit.ListAuditHandler).Methods("GET")
+	api.HandleFunc("/auth/keys", keys.IssueKeyHandler).Methods("POST")
+	api.HandleFunc("/auth/keys", keys.ListKeysHandler).Methods("GET")
+	api.HandleFunc("/auth/keys/{id}", keys.RevokeKeyHandler).Methods("DELETE")
 	return r
 }
 
-func main() {
-	connStirng := "host=localhost port=3306 user=mysql password=secret dbname=books sslmode=disable"
-	db, err := sql.Open("mysql", connStirng)
+func migrate(db *sql.DB, dialect string, args []string) error {
+	m, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		n, err := m.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		n, err := m.Down(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+	case "version":
+		version, err := m.Version()
+		if err != nil {
+			return err
+		}
+		pending, err := m.Pending()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version %d, %d pending\n", version, len(pending))
+	default:
+		return fmt.Errorf("usage: migrate [up|down [steps]|version]")
+	}
+	return nil
+}
+
+func apikey(ctx context.Context, auth *application.AuthService, args []string) error {
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch {
+	case command == "create" && (len(args) == 2 || len(args) == 3):
+		var roles []string
+		if len(args) == 3 {
+			roles = strings.Split(args[2], ",")
+		}
+		key, secret, err := auth.IssueAPIKey(ctx, args[1], roles)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created api key %d\n%s\n", key.ID, secret)
+	case command == "list" && len(args) == 1:
+		keys, err := auth.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			status := "active"
+			if key.RevokedAt != nil {
+				status = "revoked"
+			}
+			fmt.Printf("%d\t%s\t%s...\t%s\t%s\n", key.ID, key.Name, key.Prefix, strings.Join(key.Roles, ","), status)
+		}
+	case command == "revoke" && len(args) == 2:
+		ID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid key id %q", args[1])
+		}
+		if err := auth.RevokeAPIKey(ctx, ID); err != nil {
+			return err
+		}
+		fmt.Printf("revoked api key %d\n", ID)
+	default:
+		return fmt.Errorf("usage: apikey [create <name> [role,...]|list|revoke <id>]")
+	}
+	return nil
+}
+
+// openDB opens the pool through otelsql so every statement gets a span
+// under the request that ran it.
+func openDB(driver, dsn string, pool config.DB, system attribute.KeyValue) *sql.DB {
+	db, err := otelsql.Open(driver, dsn,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
 	if err != nil {
 		panic(err)
 	}
-	defer db.Close()
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
 	err = db.Ping()
 	if err != nil {
 		panic(err)
 	}
+	return db
+}
 
-	repo := infrastucture.NewBookRepositoryDB(db)
-	service := application.NewBookService(repo)
+func main() {
+	cfg, args, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) != 2 || args[1] != "print" {
+			fmt.Fprintln(os.Stderr, "usage: config print")
+			os.Exit(2)
+		}
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logger := logging.New(os.Stderr, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	var (
+		repo   domain.BookRepository
+		keys   domain.APIKeyRepository
+		audits domain.AuditRepository
+		idem   domain.IdempotencyRepository
+		tx     domain.Transactor
+		db     *sql.DB
+	)
+	switch cfg.Store {
+	case "memory":
+		repo = infrastucture.NewBookRepositoryMemory()
+		keys = infrastucture.NewAPIKeyRepositoryMemory()
+		audits = infrastucture.NewAuditRepositoryMemory()
+		idem = infrastucture.NewIdempotencyRepositoryMemory()
+		tx = infrastucture.NewMemoryTransactor()
+	case "mysql":
+		dsn, err := mysql.ParseDSN(cfg.DSN)
+		if err != nil {
+			panic(err)
+		}
+		// created_at and updated_at are scanned into time.Time.
+		dsn.ParseTime = true
+		db = openDB("mysql", dsn.FormatDSN(), cfg.DB, semconv.DBSystemMySQL)
+		repo = infrastucture.NewBookRepositoryDB(db)
+		keys = infrastucture.NewAPIKeyRepositoryDB(db)
+		audits = infrastucture.NewAuditRepositoryDB(db)
+		idem = infrastucture.NewIdempotencyRepositoryDB(db)
+		tx = infrastucture.NewSQLTransactor(db)
+	case "postgres":
+		db = openDB("postgres", cfg.DSN, cfg.DB, semconv.DBSystemPostgreSQL)
+		repo = infrastucture.NewBookRepositoryPostgres(db)
+		keys = infrastucture.NewAPIKeyRepositoryPostgres(db)
+		audits = infrastucture.NewAuditRepositoryPostgres(db)
+		idem = infrastucture.NewIdempotencyRepositoryPostgres(db)
+		tx = infrastucture.NewSQLTransactor(db)
+	case "sqlite":
+		path := "books.db"
+		if cfg.DSN != "" {
+			path = cfg.DSN
+		}
+		db = openDB("sqlite3", infrastucture.SQLiteDSN(path), cfg.DB, semconv.DBSystemSqlite)
+		repo = infrastucture.NewBookRepositorySQLite(db)
+		keys = infrastucture.NewAPIKeyRepositorySQLite(db)
+		audits = infrastucture.NewAuditRepositorySQLite(db)
+		idem = infrastucture.NewIdempotencyRepositorySQLite(db)
+		tx = infrastucture.NewSQLTransactor(db)
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		if db == nil {
+			fmt.Fprintf(os.Stderr, "store %q has no migrations\n", cfg.Store)
+			os.Exit(2)
+		}
+		err := migrate(db, cfg.Store, args[1:])
+		db.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var tokens domain.TokenVerifier
+	if cfg.Auth.JWKSFile != "" {
+		keySet, err := infrastucture.LoadJWTKeySet(cfg.Auth.JWKSFile, cfg.Auth.Issuer, cfg.Auth.Audience)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tokens = keySet
+	}
+	auth := application.NewAuthService(keys, tokens)
+	if len(args) > 0 && args[0] == "apikey" {
+		if db == nil {
+			fmt.Fprintf(os.Stderr, "store %q does not keep api keys between runs\n", cfg.Store)
+			os.Exit(2)
+		}
+		err := apikey(context.Background(), auth, args[1:])
+		db.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.Auth.Enabled && db == nil {
+		// The memory store starts empty, so hand out an admin key to get in.
+		// The secret goes to stderr once, outside the structured log.
+		key, secret, err := auth.IssueAPIKey(context.Background(), "bootstrap", []string{domain.RoleAdmin})
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "bootstrap admin api key: %s\n", secret)
+		slog.Warn("issued bootstrap admin api key for the memory store", "id", key.ID, "prefix", key.Prefix)
+	}
+
+	build := buildInfo()
+	flushTraces, err := tracing.Setup(context.Background(), cfg.Tracing, build.Commit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	repo = infrastucture.NewBookRepositoryTracing(repo, otel.GetTracerProvider())
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	repo = infrastucture.NewBookRepositoryMetrics(repo, reg)
+
+	var checks []interfaces.ReadinessCheck
+	if db != nil {
+		reg.MustRegister(collectors.NewDBStatsCollector(db, cfg.Store))
+		migrator, err := migrations.NewMigrator(db, cfg.Store)
+		if err != nil {
+			panic(err)
+		}
+		checks = append(checks,
+			interfaces.ReadinessCheck{Name: "database", Check: db.PingContext},
+			interfaces.ReadinessCheck{Name: "migrations", Check: migrator.Check},
+		)
+	}
+
+	service := application.NewAuditedBookService(repo, audits, tx)
 	handler := interfaces.NewBookHandler(service)
-	r := routes(handler)
-	http.ListenAndServe(":8080", r)
+	keyHandler := interfaces.NewAuthHandler(auth)
+	auditHandler := interfaces.NewAuditHandler(application.NewAuditService(audits))
+	idempotency := application.NewIdempotencyService(idem, tx, cfg.Idempotency.TTL)
+	// A claimed key outlives any request that is still running.
+	idempotency.Lease = max(idempotency.Lease, 2*cfg.RequestTimeout)
+	health := interfaces.NewHealthHandler(build, checks...)
+	app := &lifecycle{
+		server: &http.Server{
+			Handler:      routes(handler, keyHandler, auditHandler, auth, infrastucture.NewRateLimitStoreMemory(), idempotency, health, reg, cfg),
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+		flush:           []func(ctx context.Context) error{flushTraces},
+		db:              db,
+		shutdownTimeout: cfg.ShutdownTimeout,
+	}
+	app.workers = append(app.workers, idempotency.Run)
+	if cfg.Features.Purge {
+		app.workers = append(app.workers, application.NewAuditedPurger(repo, audits, tx, cfg.Trash.Retention, cfg.Trash.PurgeInterval).Run)
+	}
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if db != nil {
+			db.Close()
+		}
+		os.Exit(1)
+	}
+	slog.Info("listening", "addr", ln.Addr().String())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.run(ctx, ln); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,290 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Migration is one schema change. Check, when set, is a query run before
+// Up that lists the ids of rows Up cannot convert; any row it returns
+// stops the migration before a statement of Up has run.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	Check   string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Load reads the embedded migrations for dialect, sorted by version.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// with an optional <version>_<name>.check.sql.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported migration dialect %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		case strings.HasSuffix(name, ".check.sql"):
+			direction = "check"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", name, err)
+		}
+
+		body, err := fs.ReadFile(files, path.Join(dialect, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		switch direction {
+		case "up":
+			m.Up = string(body)
+		case "down":
+			m.Down = string(body)
+		case "check":
+			m.Check = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]bool, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := m.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+func (m *Migrator) Pending() ([]Migration, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check reports an error unless every migration has been applied. Unlike
+// Pending it never creates the version table, so it is safe to call from a
+// readiness probe.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	var pending int
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migration(s) pending", pending)
+	}
+	return nil
+}
+
+// Up applies every pending migration in version order and returns how many ran.
+func (m *Migrator) Up() (int, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return 0, err
+	}
+	for i, migration := range pending {
+		if err := m.run(migration.Check, migration.Up, `INSERT INTO schema_migrations (version) VALUES (?)`, migration.Version); err != nil {
+			return i, fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// Down rolls back the last steps applied migrations and returns how many ran.
+func (m *Migrator) Down(steps int) (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for i := len(m.migrations) - 1; i >= 0 && done < steps; i-- {
+		migration := m.migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+		}
+		if err := m.run("", migration.Down, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+			return done, fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		done++
+	}
+	return done, nil
+}
+
+// run executes script and records version in one transaction, once check
+// has found nothing wrong. Note that MySQL commits DDL implicitly, so a
+// failing script may be partially applied; checks exist to catch data that
+// would make it fail before anything is changed.
+func (m *Migrator) run(check, script, record string, version int) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if check != "" {
+		if err := runCheck(tx, check); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, stmt := range Statements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if m.dialect == "postgres" {
+		record = strings.Replace(record, "?", "$1", 1)
+	}
+	if _, err := tx.Exec(record, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// runCheck fails with the ids check returns, if any.
+func runCheck(tx *sql.Tx, check string) error {
+	rows, err := tx.Query(check)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("rows with id %s cannot be migrated; fix them and try again", strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// Statements splits script into individual statements on semicolons that end a line.
+func Statements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";\n") {
+		stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
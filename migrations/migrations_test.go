@@ -0,0 +1,362 @@
+package migrations_test
+
+import (
+	"book-apis/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	for _, dialect := range []string{"mysql", "sqlite", "postgres"} {
+		t.Run(dialect, func(t *testing.T) {
+			ms, err := migrations.Load(dialect)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, ms)
+			assert.Equal(t, 1, ms[0].Version)
+			assert.Equal(t, "create_books", ms[0].Name)
+			assert.Contains(t, ms[0].Up, "CREATE TABLE books")
+			assert.Contains(t, ms[0].Down, "DROP TABLE books")
+			for i := 1; i < len(ms); i++ {
+				assert.Less(t, ms[i-1].Version, ms[i].Version)
+			}
+		})
+	}
+
+	_, err := migrations.Load("oracle")
+	assert.Error(t, err)
+}
+
+func TestLoad_SameVersionsAcrossDialects(t *testing.T) {
+	versions := func(dialect string) []string {
+		ms, err := migrations.Load(dialect)
+		assert.NoError(t, err)
+		var names []string
+		for _, m := range ms {
+			names = append(names, fmt.Sprintf("%d_%s", m.Version, m.Name))
+		}
+		return names
+	}
+	assert.Equal(t, versions("mysql"), versions("sqlite"))
+	assert.Equal(t, versions("mysql"), versions("postgres"))
+}
+
+func TestMigrator_Up(t *testing.T) {
+	type testCase struct {
+		name        string
+		expected    int
+		mockSetup   func(mock sqlmock.Sqlmock, all []migrations.Migration)
+		shouldError bool
+	}
+
+	tests := []testCase{
+		{
+			name:     "success - applies pending migrations",
+			expected: -1,
+			mockSetup: func(mock sqlmock.Sqlmock, all []migrations.Migration) {
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				for _, m := range all {
+					mock.ExpectBegin()
+					if m.Check != "" {
+						mock.ExpectQuery(".+").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+					}
+					for range len(migrations.Statements(m.Up)) {
+						mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+					}
+					mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(m.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			},
+		},
+		{
+			name:     "success - nothing pending",
+			expected: 0,
+			mockSetup: func(mock sqlmock.Sqlmock, all []migrations.Migration) {
+				rows := sqlmock.NewRows([]string{"version"})
+				for _, m := range all {
+					rows.AddRow(m.Version)
+				}
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(rows)
+			},
+		},
+		{
+			name:     "failure - script fails",
+			expected: 0,
+			mockSetup: func(mock sqlmock.Sqlmock, all []migrations.Migration) {
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version"}))
+				mock.ExpectBegin()
+				mock.ExpectExec(".+").WillReturnError(fmt.Errorf("Some DB error"))
+				mock.ExpectRollback()
+			},
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Error initializing sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			m, err := migrations.NewMigrator(db, "mysql")
+			assert.NoError(t, err)
+			tc.mockSetup(mock, m.Migrations())
+
+			expected := tc.expected
+			if expected < 0 {
+				expected = len(m.Migrations())
+			}
+
+			n, err := m.Up()
+			if tc.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, expected, n)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, "mysql")
+	assert.NoError(t, err)
+	all := m.Migrations()
+	last := all[len(all)-1]
+
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, migration := range all {
+		rows.AddRow(migration.Version)
+	}
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(rows)
+	mock.ExpectBegin()
+	for range len(migrations.Statements(last.Down)) {
+		mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE version = ?").WithArgs(last.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := m.Down(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Version(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, "mysql")
+	assert.NoError(t, err)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT MAX\\(version\\) FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+
+	version, err := m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Check(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, "mysql")
+	assert.NoError(t, err)
+	versions := sqlmock.NewRows([]string{"version"})
+	for _, migration := range m.Migrations() {
+		versions.AddRow(migration.Version)
+	}
+
+	type testCase struct {
+		name      string
+		mockSetup func()
+		errMsg    string
+	}
+	tests := []testCase{
+		{
+			name: "current",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(versions)
+			},
+		},
+		{
+			name: "pending",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+			},
+			errMsg: fmt.Sprintf("%d migration(s) pending", len(m.Migrations())-1),
+		},
+		{
+			name: "no version table",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT version FROM schema_migrations").WillReturnError(fmt.Errorf("Table 'books.schema_migrations' doesn't exist"))
+			},
+			errMsg: "Table 'books.schema_migrations' doesn't exist",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockSetup()
+			err := m.Check(context.Background())
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_SQLiteRoundTrip(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, "sqlite")
+	assert.NoError(t, err)
+	total := len(m.Migrations())
+
+	n, err := m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, total, n)
+
+	version, err := m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, m.Migrations()[total-1].Version, version)
+
+	_, err = db.Exec(`INSERT INTO books (title, author, price) VALUES ('Test Title 1', 'Test Author 1', 1250)`)
+	assert.NoError(t, err)
+
+	// Rolling back to before the price migration (version 3) and
+	// re-applying it keeps the amount.
+	steps := total - 2
+	n, err = m.Down(steps)
+	assert.NoError(t, err)
+	assert.Equal(t, steps, n)
+	var text string
+	assert.NoError(t, db.QueryRow(`SELECT price FROM books`).Scan(&text))
+	assert.Equal(t, "12.50", text)
+
+	n, err = m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, steps, n)
+	var minor int64
+	var currency string
+	assert.NoError(t, db.QueryRow(`SELECT price, currency FROM books`).Scan(&minor, &currency))
+	assert.Equal(t, int64(1250), minor)
+	assert.Equal(t, "USD", currency)
+
+	n, err = m.Down(total)
+	assert.NoError(t, err)
+	assert.Equal(t, total, n)
+
+	version, err = m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	n, err = m.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, total, n)
+}
+
+func TestMigrator_SQLiteCheckStopsPriceMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, "sqlite")
+	assert.NoError(t, err)
+	total := len(m.Migrations())
+	_, err = m.Up()
+	assert.NoError(t, err)
+	_, err = m.Down(total - 2)
+	assert.NoError(t, err)
+
+	for _, price := range []string{"12.50", "", " 3 ", "abc", "1.2.3", "$5", "1234567890123456", "9.995", "7.5"} {
+		_, err = db.Exec(`INSERT INTO books (title, author, price) VALUES ('Test Title', 'Test Author', ?)`, price)
+		assert.NoError(t, err)
+	}
+
+	_, err = m.Up()
+	assert.ErrorContains(t, err, "rows with id 4, 5, 6, 7, 8 cannot be migrated")
+	version, err := m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	var price string
+	assert.NoError(t, db.QueryRow(`SELECT price FROM books WHERE id = 4`).Scan(&price))
+	assert.Equal(t, "abc", price)
+
+	_, err = db.Exec(`DELETE FROM books WHERE id BETWEEN 4 AND 8`)
+	assert.NoError(t, err)
+	_, err = m.Up()
+	assert.NoError(t, err)
+	rows, err := db.Query(`SELECT price FROM books ORDER BY id`)
+	assert.NoError(t, err)
+	defer rows.Close()
+	var prices []int64
+	for rows.Next() {
+		var minor int64
+		assert.NoError(t, rows.Scan(&minor))
+		prices = append(prices, minor)
+	}
+	assert.Equal(t, []int64{1250, 0, 300, 750}, prices)
+}
+
+func TestMigrator_SQLiteLegacyBooksTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE books (title TEXT, author TEXT, genre TEXT, price TEXT, stock INTEGER)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO books VALUES ('Test Title', 'Test Author', 'Horror', '12.50', 1)`)
+	assert.NoError(t, err)
+
+	m, err := migrations.NewMigrator(db, "sqlite")
+	assert.NoError(t, err)
+	_, err = m.Up()
+	assert.ErrorContains(t, err, "already exists")
+	version, err := m.Version()
+	assert.NoError(t, err)
+	assert.Zero(t, version)
+	var title string
+	assert.NoError(t, db.QueryRow(`SELECT title FROM books`).Scan(&title))
+	assert.Equal(t, "Test Title", title)
+}
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN price_text VARCHAR(32) NOT NULL DEFAULT '' AFTER price;
+UPDATE books SET price_text = CAST(CAST(price AS DECIMAL(19,2)) / 100 AS DECIMAL(19,2));
+ALTER TABLE books DROP COLUMN price, DROP COLUMN currency;
+ALTER TABLE books RENAME COLUMN price_text TO price;
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0 AFTER price, ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER price_minor;
+UPDATE books SET price_minor = ROUND(CAST(COALESCE(NULLIF(TRIM(price), ''), '0') AS DECIMAL(19,4)) * 100);
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books RENAME COLUMN price_minor TO price;
@@ -0,0 +1 @@
+ALTER TABLE books DROP INDEX books_search;
@@ -0,0 +1 @@
+ALTER TABLE books ADD FULLTEXT INDEX books_search (title, author, genre);
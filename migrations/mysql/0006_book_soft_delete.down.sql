@@ -0,0 +1,3 @@
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+DROP INDEX books_deleted_at ON books;
+ALTER TABLE books DROP COLUMN deleted_at;
@@ -0,0 +1,2 @@
+ALTER TABLE books ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+CREATE INDEX books_deleted_at ON books (deleted_at);
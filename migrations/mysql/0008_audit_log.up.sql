@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    book_id BIGINT NOT NULL,
+    changes TEXT NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY audit_log_book_id (book_id, id),
+    KEY audit_log_actor (actor, id)
+);
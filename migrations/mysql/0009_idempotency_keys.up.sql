@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner VARCHAR(255) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    header TEXT NOT NULL,
+    body MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (owner, idem_key),
+    KEY idempotency_keys_expires_at (expires_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN isbn VARCHAR(17) NOT NULL DEFAULT '';
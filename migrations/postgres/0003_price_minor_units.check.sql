@@ -0,0 +1 @@
+SELECT id FROM books WHERE TRIM(price) <> '' AND TRIM(price) !~ '^[0-9]{1,15}([.][0-9]{1,2})?$' ORDER BY id
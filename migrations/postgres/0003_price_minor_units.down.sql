@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN price_text VARCHAR(32) NOT NULL DEFAULT '';
+UPDATE books SET price_text = to_char(price / 100.0, 'FM999999999999990.00');
+ALTER TABLE books DROP COLUMN price, DROP COLUMN currency;
+ALTER TABLE books RENAME COLUMN price_text TO price;
@@ -0,0 +1,4 @@
+ALTER TABLE books ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0, ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+UPDATE books SET price_minor = ROUND(COALESCE(NULLIF(TRIM(price), ''), '0')::numeric * 100);
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books RENAME COLUMN price_minor TO price;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS books_search;
+ALTER TABLE books DROP COLUMN search;
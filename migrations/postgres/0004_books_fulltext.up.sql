@@ -0,0 +1,6 @@
+ALTER TABLE books ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', author), 'B') ||
+    setweight(to_tsvector('simple', genre), 'C')
+) STORED;
+CREATE INDEX books_search ON books USING GIN (search);
@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS books_deleted_at;
+ALTER TABLE books DROP COLUMN deleted_at;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    book_id BIGINT NOT NULL,
+    changes TEXT NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_log_book_id ON audit_log (book_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor, id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner VARCHAR(255) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    header TEXT NOT NULL,
+    body BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (owner, idem_key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at ON idempotency_keys (expires_at);
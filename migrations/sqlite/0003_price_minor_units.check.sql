@@ -0,0 +1,4 @@
+SELECT id FROM books WHERE TRIM(price) <> '' AND (
+    TRIM(price) GLOB '*[^0-9.]*' OR TRIM(price) GLOB '*.*.*' OR TRIM(price) GLOB '.*' OR TRIM(price) GLOB '*.'
+    OR TRIM(price) GLOB '*.[0-9][0-9][0-9]*' OR INSTR(TRIM(price) || '.', '.') > 16
+) ORDER BY id
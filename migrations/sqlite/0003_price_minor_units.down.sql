@@ -0,0 +1,5 @@
+ALTER TABLE books ADD COLUMN price_text TEXT NOT NULL DEFAULT '';
+UPDATE books SET price_text = printf('%.2f', price / 100.0);
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books DROP COLUMN currency;
+ALTER TABLE books RENAME COLUMN price_text TO price;
@@ -0,0 +1,5 @@
+ALTER TABLE books ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE books ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+UPDATE books SET price_minor = CAST(ROUND(CAST(COALESCE(NULLIF(TRIM(price), ''), '0') AS REAL) * 100) AS INTEGER);
+ALTER TABLE books DROP COLUMN price;
+ALTER TABLE books RENAME COLUMN price_minor TO price;
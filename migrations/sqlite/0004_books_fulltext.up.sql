@@ -0,0 +1,6 @@
+CREATE VIRTUAL TABLE books_fts USING fts4(content="books", title, author, genre, tokenize=unicode61);
+INSERT INTO books_fts(books_fts) VALUES ('rebuild');
+CREATE TRIGGER books_fts_bu BEFORE UPDATE ON books BEGIN DELETE FROM books_fts WHERE docid = old.id; END;
+CREATE TRIGGER books_fts_bd BEFORE DELETE ON books BEGIN DELETE FROM books_fts WHERE docid = old.id; END;
+CREATE TRIGGER books_fts_au AFTER UPDATE ON books BEGIN INSERT INTO books_fts(docid, title, author, genre) VALUES (new.id, new.title, new.author, new.genre); END;
+CREATE TRIGGER books_fts_ai AFTER INSERT ON books BEGIN INSERT INTO books_fts(docid, title, author, genre) VALUES (new.id, new.title, new.author, new.genre); END;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    book_id INTEGER NOT NULL,
+    changes TEXT NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS audit_log_book_id ON audit_log (book_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor, id);
@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"book-apis/domain"
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) (*domain.APIKey, error) {
+	args := m.Called(key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) FindAPIKey(ctx context.Context, hash string) (domain.APIKey, error) {
+	args := m.Called(hash)
+	return args.Get(0).(domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, ID int) error {
+	args := m.Called(ID)
+	return args.Error(0)
+}
+
+type MockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *MockTokenVerifier) VerifyToken(ctx context.Context, token string) (domain.Principal, error) {
+	args := m.Called(token)
+	return args.Get(0).(domain.Principal), args.Error(1)
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"book-apis/domain"
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) RecordAudit(ctx context.Context, entry *domain.AuditEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) ListAudit(ctx context.Context, query domain.AuditQuery) ([]domain.AuditEntry, error) {
+	args := m.Called(query)
+	return args.Get(0).([]domain.AuditEntry), args.Error(1)
+}
+
+// MockTransactor runs functions straight away and counts them.
+type MockTransactor struct {
+	Calls int
+}
+
+func (m *MockTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.Calls++
+	return fn(ctx)
+}
@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"book-apis/domain"
+	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -10,17 +12,17 @@ type MockBookRepository struct {
 	mock.Mock
 }
 
-func (m *MockBookRepository) GetAll() ([]domain.Book, error) {
-	args := m.Called()
+func (m *MockBookRepository) GetAll(ctx context.Context, query domain.BookQuery) ([]domain.Book, error) {
+	args := m.Called(query)
 	return args.Get(0).([]domain.Book), args.Error(1)
 }
 
-func (m *MockBookRepository) GetBook(ID int) (domain.Book, error) {
+func (m *MockBookRepository) GetBook(ctx context.Context, ID int) (domain.Book, error) {
 	args := m.Called(ID)
 	return args.Get(0).(domain.Book), args.Error(1)
 }
 
-func (m *MockBookRepository) CreateBook(book *domain.Book) (*domain.Book, error) {
+func (m *MockBookRepository) CreateBook(ctx context.Context, book *domain.Book) (*domain.Book, error) {
 	args := m.Called(book)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -28,7 +30,7 @@ func (m *MockBookRepository) CreateBook(book *domain.Book) (*domain.Book, error)
 	return args.Get(0).(*domain.Book), args.Error(1)
 }
 
-func (m *MockBookRepository) UpdateBook(book *domain.Book, ID int) (*domain.Book, error) {
+func (m *MockBookRepository) UpdateBook(ctx context.Context, book *domain.Book, ID int) (*domain.Book, error) {
 	args := m.Called(book, ID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -36,7 +38,39 @@ func (m *MockBookRepository) UpdateBook(book *domain.Book, ID int) (*domain.Book
 	return args.Get(0).(*domain.Book), args.Error(1)
 }
 
-func (m *MockBookRepository) DeleteBook(ID int) error {
-	args := m.Called(ID)
+func (m *MockBookRepository) PatchBook(ctx context.Context, patch domain.BookPatch, ID int) (*domain.Book, error) {
+	args := m.Called(patch, ID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Book), args.Error(1)
+}
+
+func (m *MockBookRepository) DeleteBook(ctx context.Context, ID int, version int) error {
+	args := m.Called(ID, version)
 	return args.Error(0)
 }
+
+func (m *MockBookRepository) Search(ctx context.Context, query string, limit int) ([]domain.SearchResult, error) {
+	args := m.Called(query, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SearchResult), args.Error(1)
+}
+
+func (m *MockBookRepository) RestoreBook(ctx context.Context, ID int) (*domain.Book, error) {
+	args := m.Called(ID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Book), args.Error(1)
+}
+
+func (m *MockBookRepository) PurgeDeleted(ctx context.Context, before time.Time) ([]int, error) {
+	args := m.Called(before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"book-apis/domain"
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) CreateIdempotencyKey(ctx context.Context, record *domain.IdempotencyRecord) (bool, error) {
+	args := m.Called(record)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) GetIdempotencyKey(ctx context.Context, owner, key string) (domain.IdempotencyRecord, error) {
+	args := m.Called(owner, key)
+	return args.Get(0).(domain.IdempotencyRecord), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) SaveIdempotentResponse(ctx context.Context, record *domain.IdempotencyRecord) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteIdempotencyKey(ctx context.Context, owner, key string) error {
+	args := m.Called(owner, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	args := m.Called(before)
+	return args.Int(0), args.Error(1)
+}
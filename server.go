@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lifecycle runs the HTTP server next to the background workers and tears
+// them down in order: stop accepting and drain requests, stop the workers,
+// flush buffered telemetry, then close the database pool they all share.
+type lifecycle struct {
+	server          *http.Server
+	workers         []func(ctx context.Context)
+	flush           []func(ctx context.Context) error
+	db              *sql.DB
+	shutdownTimeout time.Duration
+}
+
+// run serves on ln until ctx is done or the server fails.
+func (l *lifecycle) run(ctx context.Context, ln net.Listener) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for _, work := range l.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			work(workerCtx)
+		}()
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- l.server.Serve(ln)
+	}()
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		slog.Info("shutting down", "drain_timeout", l.shutdownTimeout.String())
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+		defer cancel()
+		if err = l.server.Shutdown(shutdownCtx); err != nil {
+			l.server.Close()
+		}
+	}
+
+	stopWorkers()
+	workers.Wait()
+	flushCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+	for _, flush := range l.flush {
+		if flushErr := flush(flushCtx); err == nil {
+			err = flushErr
+		}
+	}
+	if l.db != nil {
+		if closeErr := l.db.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
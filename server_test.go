@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing sqlmock: %v", err)
+	}
+	mock.ExpectClose()
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		record("request done")
+		io.WriteString(w, "ok")
+	})
+	app := &lifecycle{
+		server: &http.Server{Handler: handler},
+		workers: []func(ctx context.Context){func(ctx context.Context) {
+			<-ctx.Done()
+			record("worker stopped")
+		}},
+		flush: []func(ctx context.Context) error{func(ctx context.Context) error {
+			record("flushed")
+			return nil
+		}},
+		db:              db,
+		shutdownTimeout: time.Second,
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.run(ctx, ln) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	stop()
+
+	got := <-response
+	assert.NoError(t, got.err)
+	assert.Equal(t, "ok", got.body)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"request done", "worker stopped", "flushed"}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = http.Get("http://" + ln.Addr().String())
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package tracing_test
+
+import (
+	"book-apis/config"
+	"book-apis/tracing"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	flush, err := tracing.Setup(context.Background(), config.Tracing{Exporter: "none"}, "dev")
+	assert.NoError(t, err)
+	assert.NoError(t, flush(context.Background()))
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+	_, err = tracing.Setup(context.Background(), config.Tracing{Exporter: "zipkin"}, "dev")
+	assert.EqualError(t, err, `unknown trace exporter "zipkin"`)
+
+	flush, err = tracing.Setup(context.Background(), config.Tracing{Exporter: "otlp", Endpoint: "http://127.0.0.1:4318", SampleRatio: 1}, "dev")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	flush(ctx)
+}